@@ -7,7 +7,11 @@ import (
 	"platform-service/internal/config"
 	"platform-service/internal/database"
 	"platform-service/internal/handlers"
+	"platform-service/internal/mail"
 	internal_middleware "platform-service/internal/middleware"
+	"platform-service/internal/models"
+	"platform-service/internal/passwords"
+	"platform-service/internal/services"
 	"platform-service/internal/utils"
 	"time"
 
@@ -78,31 +82,170 @@ func main() {
 		panic(err)
 	}
 
+	if err := services.InitKeyring(); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
+	if err := services.InitRevocationStore(); err != nil {
+		log.Fatalf("Failed to load revoked tokens: %v", err)
+	}
+
+	if err := services.InitWebAuthn(); err != nil {
+		log.Fatalf("Failed to configure WebAuthn: %v", err)
+	}
+
+	services.InitLoginThrottle()
+
+	if err := mail.Init(); err != nil {
+		log.Fatalf("Failed to configure mail: %v", err)
+	}
+
+	if err := services.InitSAML(); err != nil {
+		log.Fatalf("Failed to configure SAML: %v", err)
+	}
+
+	if err := passwords.Init(); err != nil {
+		log.Fatalf("Failed to configure passwords: %v", err)
+	}
+
+	if err := services.InitAuthenticators(); err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+
 	metricsMiddleware, err := internal_middleware.NewMetricsMiddleware()
 	if err != nil {
 		log.Fatalf("Failed to create metrics middleware: %v", err)
 	}
 
+	var rateLimitStore internal_middleware.RateLimitStore
+	if config.IsRateLimitEnabled() {
+		rateLimitStore, err = internal_middleware.NewRateLimitStore(config.GetRateLimitStore())
+		if err != nil {
+			log.Fatalf("Failed to create rate limit store: %v", err)
+		}
+	}
+	limiter := internal_middleware.NewRateLimiter(rateLimitStore)
+	loginLimit := limiter.Limit("login", config.GetRateLimit("login", config.RateLimit{Limit: 10, Period: time.Minute, Key: "ip"}))
+	registerLimit := limiter.Limit("register", config.GetRateLimit("register", config.RateLimit{Limit: 5, Period: time.Hour, Key: "ip"}))
+	emailLimit := limiter.Limit("email", config.GetRateLimit("email", config.RateLimit{Limit: 5, Period: time.Hour, Key: "ip"}))
+	tokenLimit := limiter.Limit("token", config.GetRateLimit("token", config.RateLimit{Limit: 60, Period: time.Minute, Key: "client"}))
+	apiLimit := limiter.Limit("api", config.GetRateLimit("api", config.RateLimit{Limit: 300, Period: time.Minute, Key: "user"}))
+
 	e := echo.New()
+	e.IPExtractor = internal_middleware.IPExtractor(config.GetTrustedProxies())
 	e.Use(middleware.Logger())
 	e.Use(metricsMiddleware.Middleware())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: config.GetAllowedOrigins(),
 		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
 		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept},
+		ExposeHeaders: []string{
+			echo.HeaderRetryAfter, "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+		},
 	}))
 
-	e.POST("/register", handlers.Register)
-	e.POST("/login", handlers.Login)
+	e.GET("/.well-known/jwks.json", handlers.GetJWKS)
+	e.GET("/.well-known/openid-configuration", handlers.GetOpenIDConfiguration)
+
+	e.POST("/register", handlers.Register, registerLimit)
+	e.POST("/verify-email", handlers.VerifyEmail, loginLimit)
+	e.POST("/verify-email/resend", handlers.ResendVerificationEmail, emailLimit)
+	e.POST("/password/forgot", handlers.ForgotPassword, emailLimit)
+	e.POST("/password/reset", handlers.ResetPassword, loginLimit)
+	e.POST("/login", handlers.Login, loginLimit)
+	e.POST("/login/mfa", handlers.LoginMFA, loginLimit)
+	e.POST("/login/mfa/enroll", handlers.LoginMFAEnroll, loginLimit)
+	e.POST("/login/mfa/enroll/confirm", handlers.LoginMFAEnrollConfirm, loginLimit)
+	e.POST("/login/webauthn/begin", handlers.BeginPasskeyLogin, loginLimit)
+	e.POST("/login/webauthn/finish", handlers.FinishPasskeyLogin, loginLimit)
+	e.POST("/login/magic-link", handlers.RequestMagicLink, emailLimit)
+	e.GET("/login/magic-link/callback", handlers.MagicLinkCallback, loginLimit)
+	e.GET("/login/oidc", handlers.ListFederationProviders)
+	e.GET("/login/oidc/:provider", handlers.BeginFederatedLogin, loginLimit)
+	e.GET("/login/oidc/:provider/callback", handlers.FederatedLoginCallback, loginLimit)
+	e.GET("/saml/metadata", handlers.SAMLMetadata)
+	e.GET("/saml/login", handlers.BeginSAMLLogin, loginLimit)
+	e.POST("/saml/acs", handlers.SAMLAssertionConsumer, loginLimit)
+	e.POST("/token/refresh", handlers.RefreshToken, tokenLimit)
+
+	e.GET("/oauth/authorize", handlers.Authorize)
+	e.POST("/oauth/authorize", handlers.AuthorizeSubmit, loginLimit)
+	e.POST("/oauth/token", handlers.Token, tokenLimit)
+	e.POST("/oauth/introspect", handlers.IntrospectToken, tokenLimit)
+	e.POST("/oauth/revoke", handlers.RevokeToken, tokenLimit)
+
+	jwtMiddleware := echojwt.WithConfig(utils.JWTConfig(false))
+	clientJWTMiddleware := echojwt.WithConfig(utils.JWTConfig(true))
+	e.POST("/logout", handlers.Logout, jwtMiddleware, internal_middleware.AuthMiddleware)
+	e.POST("/logout/all", handlers.LogoutAll, jwtMiddleware, internal_middleware.AuthMiddleware, internal_middleware.RejectImpersonation)
+
+	// Unlike the rest of /api, userinfo serves tokens issued to OAuth clients.
+	userInfoAuth := []echo.MiddlewareFunc{internal_middleware.BearerAuth(clientJWTMiddleware), internal_middleware.AuthMiddleware, apiLimit}
+	e.GET("/api/userinfo", handlers.GetUserInfo, userInfoAuth...)
+	e.POST("/api/userinfo", handlers.GetUserInfo, userInfoAuth...)
 
 	r := e.Group("/api")
-	r.Use(echojwt.WithConfig(utils.JWTConfig()))
+	r.Use(internal_middleware.BearerAuth(jwtMiddleware))
+	r.Use(internal_middleware.AuthMiddleware)
+	r.Use(apiLimit)
 
 	r.GET("/protected", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{"message": "You are authenticated"})
-	}, internal_middleware.AuthMiddleware)
-
-	r.GET("/metrics", handlers.GetMetricsHandler(metricsMiddleware), internal_middleware.AdminAuthMiddleware)
+	})
+
+	// Admins impersonating a user cannot change the user's credentials,
+	// sessions or tokens.
+	noImpersonation := internal_middleware.RejectImpersonation
+
+	r.PUT("/password", handlers.ChangePassword, noImpersonation)
+
+	r.POST("/mfa/totp", handlers.EnrollTOTP, noImpersonation)
+	r.POST("/mfa/totp/confirm", handlers.ConfirmTOTP, noImpersonation)
+	r.DELETE("/mfa/totp", handlers.DisableTOTP, noImpersonation)
+	r.POST("/mfa/recovery-codes", handlers.RegenerateRecoveryCodes, noImpersonation)
+
+	r.POST("/webauthn/register/begin", handlers.BeginPasskeyRegistration, noImpersonation)
+	r.POST("/webauthn/register/finish", handlers.FinishPasskeyRegistration, noImpersonation)
+	r.GET("/webauthn/credentials", handlers.ListPasskeys)
+	r.DELETE("/webauthn/credentials/:id", handlers.DeletePasskey, noImpersonation)
+
+	r.GET("/sessions", handlers.ListSessions)
+	r.DELETE("/sessions/:id", handlers.RevokeSession, noImpersonation)
+	r.POST("/sessions/revoke-others", handlers.RevokeOtherSessions, noImpersonation)
+
+	r.GET("/tokens", handlers.ListPersonalAccessTokens)
+	r.POST("/tokens", handlers.CreatePersonalAccessToken, noImpersonation)
+	r.DELETE("/tokens/:id", handlers.RevokePersonalAccessToken, noImpersonation)
+
+	r.POST("/impersonation/end", handlers.EndImpersonation)
+
+	requirePermission := internal_middleware.RequirePermission
+
+	r.GET("/metrics", handlers.GetMetricsHandler(metricsMiddleware), requirePermission(models.PermissionMetricsRead))
+
+	admin := r.Group("/admin")
+	admin.PUT("/users/:uid/status", handlers.UpdateUserStatus, requirePermission(models.PermissionUsersWrite))
+	admin.POST("/users/:uid/revoke-tokens", handlers.RevokeUserTokens, requirePermission(models.PermissionUsersWrite))
+	admin.POST("/users/:uid/unlock", handlers.UnlockUser, requirePermission(models.PermissionUsersWrite))
+	admin.POST("/users/:uid/impersonate", handlers.ImpersonateUser, requirePermission(models.PermissionUsersImpersonate))
+	admin.GET("/audit-logs", handlers.ListAuditLogs, requirePermission(models.PermissionAuditRead))
+	admin.GET("/users/:uid/sessions", handlers.ListUserSessions, requirePermission(models.PermissionUsersRead))
+	admin.DELETE("/users/:uid/sessions/:id", handlers.RevokeUserSession, requirePermission(models.PermissionUsersWrite))
+	admin.GET("/users/:uid/roles", handlers.GetUserRoles, requirePermission(models.PermissionUsersRead))
+	admin.PUT("/users/:uid/roles", handlers.SetUserRoles, requirePermission(models.PermissionRolesWrite))
+	admin.GET("/keys", handlers.ListSigningKeys, requirePermission(models.PermissionKeysRead))
+	admin.POST("/keys/rotate", handlers.RotateSigningKey, requirePermission(models.PermissionKeysWrite))
+	admin.GET("/oauth/clients", handlers.ListOAuthClients, requirePermission(models.PermissionClientsRead))
+	admin.POST("/oauth/clients", handlers.CreateOAuthClient, requirePermission(models.PermissionClientsWrite))
+	admin.DELETE("/oauth/clients/:client_id", handlers.DeleteOAuthClient, requirePermission(models.PermissionClientsWrite))
+	admin.GET("/roles", handlers.ListRoles, requirePermission(models.PermissionRolesRead))
+	admin.POST("/roles", handlers.CreateRole, requirePermission(models.PermissionRolesWrite))
+	admin.GET("/roles/:name", handlers.GetRole, requirePermission(models.PermissionRolesRead))
+	admin.PUT("/roles/:name", handlers.UpdateRole, requirePermission(models.PermissionRolesWrite))
+	admin.DELETE("/roles/:name", handlers.DeleteRole, requirePermission(models.PermissionRolesWrite))
+	admin.GET("/permissions", handlers.ListPermissions, requirePermission(models.PermissionRolesRead))
+	admin.POST("/permissions", handlers.CreatePermission, requirePermission(models.PermissionRolesWrite))
+	admin.DELETE("/permissions/:name", handlers.DeletePermission, requirePermission(models.PermissionRolesWrite))
 
 	e.Logger.Fatal(e.Start(":8080"))
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"platform-service/internal/database"
+	"platform-service/internal/services"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: keys <command>
+
+Commands:
+  list     list the persisted JWT signing keys
+  rotate   make a new signing key current and retire the previous one`
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := database.InitDB(); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	if err := services.LoadKeyring(); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "list":
+		list()
+	case "rotate":
+		key, err := services.RotateSigningKey()
+		if err != nil {
+			log.Fatalf("Failed to rotate signing key: %v", err)
+		}
+		fmt.Printf("Rotated signing key, new kid: %s\n", key.KID)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func list() {
+	keys, err := services.ListSigningKeys()
+	if err != nil {
+		log.Fatalf("Failed to list signing keys: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tSTATUS\tCREATED\tEXPIRES")
+	for _, key := range keys {
+		expires := "-"
+		if key.ExpiresAt != nil {
+			expires = key.ExpiresAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.KID, key.Algorithm, key.Status, key.CreatedAt.Format(time.RFC3339), expires)
+	}
+	w.Flush()
+}
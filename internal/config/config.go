@@ -1,16 +1,25 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"net"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 func init() {
 	viper.SetConfigFile(".env")
-	if err := viper.ReadInConfig(); err != nil {
+	// Environment variables take precedence over the .env file, which may be
+	// left out when the environment provides the configuration.
+	viper.AutomaticEnv()
+	if err := viper.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatal("Error reading .env file: ", err)
 	}
 }
@@ -35,6 +44,715 @@ func GetJWTSecretKey() string {
 	return viper.GetString("JWT_SECRET_KEY")
 }
 
+func GetJWTSigningAlgorithm() string {
+	alg := viper.GetString("JWT_SIGNING_ALG")
+	if alg == "" {
+		return "HS256"
+	}
+	return alg
+}
+
+func GetJWTPrivateKeyFile() string {
+	return viper.GetString("JWT_PRIVATE_KEY_FILE")
+}
+
+func GetJWTKeyRetention() time.Duration {
+	retention := viper.GetDuration("JWT_KEY_RETENTION")
+	if retention <= 0 {
+		return 24 * time.Hour
+	}
+	return retention
+}
+
+func GetJWTMaxRetiredKeys() int {
+	if !viper.IsSet("JWT_MAX_RETIRED_KEYS") {
+		return 3
+	}
+	return viper.GetInt("JWT_MAX_RETIRED_KEYS")
+}
+
+func GetKeyringSyncInterval() time.Duration {
+	interval := viper.GetDuration("KEYRING_SYNC_INTERVAL")
+	if interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// GetDataEncryptionKey returns the key that encrypts secrets at rest, such as
+// the signing keyring. It is separate from JWT_SECRET_KEY so that either can
+// be rotated on its own.
+func GetDataEncryptionKey() string {
+	key := viper.GetString("DATA_ENCRYPTION_KEY")
+	if key == "" {
+		log.Fatal("DATA_ENCRYPTION_KEY not set")
+	}
+	return key
+}
+
+func GetOIDCIssuer() string {
+	issuer := viper.GetString("OIDC_ISSUER")
+	if issuer == "" {
+		return "http://localhost:8080"
+	}
+	return strings.TrimSuffix(issuer, "/")
+}
+
+func GetIDTokenAudience() string {
+	audience := viper.GetString("ID_TOKEN_AUDIENCE")
+	if audience == "" {
+		return "talentlens"
+	}
+	return audience
+}
+
+func GetAccessTokenTTL() time.Duration {
+	ttl := viper.GetDuration("ACCESS_TOKEN_TTL")
+	if ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+// legacyAccessTokenTTL is the lifetime of access tokens issued before
+// ACCESS_TOKEN_TTL existed.
+const legacyAccessTokenTTL = 24 * time.Hour
+
+// GetMaxAccessTokenTTL returns the longest lifetime an outstanding access
+// token may have, which revocations must outlast. Tokens issued by earlier
+// versions, or before ACCESS_TOKEN_TTL was lowered, may live up to 24 hours.
+func GetMaxAccessTokenTTL() time.Duration {
+	return max(GetAccessTokenTTL(), legacyAccessTokenTTL)
+}
+
+func GetRefreshTokenTTL() time.Duration {
+	ttl := viper.GetDuration("REFRESH_TOKEN_TTL")
+	if ttl <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return ttl
+}
+
+// GetImpersonationTTL returns how long a token issued to an admin
+// impersonating a user stays valid. It is capped at the access token TTL,
+// which is how long user-wide revocations are kept.
+func GetImpersonationTTL() time.Duration {
+	ttl := viper.GetDuration("IMPERSONATION_TTL")
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	return min(ttl, GetAccessTokenTTL())
+}
+
+func GetRevocationSyncInterval() time.Duration {
+	interval := viper.GetDuration("REVOCATION_SYNC_INTERVAL")
+	if interval <= 0 {
+		return 30 * time.Second
+	}
+	return interval
+}
+
+func GetMFAIssuer() string {
+	issuer := viper.GetString("MFA_ISSUER")
+	if issuer == "" {
+		return "TalentLens"
+	}
+	return issuer
+}
+
+func IsMFARequiredForAdmins() bool {
+	return viper.GetBool("MFA_REQUIRED_FOR_ADMINS")
+}
+
+func GetMFAChallengeTTL() time.Duration {
+	ttl := viper.GetDuration("MFA_CHALLENGE_TTL")
+	if ttl <= 0 {
+		return 5 * time.Minute
+	}
+	return ttl
+}
+
+// GetWebAuthnRPID returns the relying party ID passkeys are bound to. It
+// defaults to the host of OIDC_ISSUER.
+func GetWebAuthnRPID() string {
+	rpID := viper.GetString("WEBAUTHN_RP_ID")
+	if rpID != "" {
+		return rpID
+	}
+	issuer, err := url.Parse(GetOIDCIssuer())
+	if err != nil || issuer.Hostname() == "" {
+		return "localhost"
+	}
+	return issuer.Hostname()
+}
+
+func GetWebAuthnRPName() string {
+	name := viper.GetString("WEBAUTHN_RP_NAME")
+	if name == "" {
+		return "TalentLens"
+	}
+	return name
+}
+
+// GetWebAuthnOrigins returns the origins browsers may run ceremonies from.
+// It defaults to OIDC_ISSUER.
+func GetWebAuthnOrigins() []string {
+	origins := viper.GetString("WEBAUTHN_RP_ORIGINS")
+	if origins == "" {
+		return []string{GetOIDCIssuer()}
+	}
+	return strings.Split(origins, ",")
+}
+
+func GetWebAuthnTimeout() time.Duration {
+	timeout := viper.GetDuration("WEBAUTHN_TIMEOUT")
+	if timeout <= 0 {
+		return 5 * time.Minute
+	}
+	return timeout
+}
+
+// GetAuthBackends returns the user stores passwords are checked against, in
+// order: "database" and "ldap".
+func GetAuthBackends() []string {
+	var backends []string
+	for _, backend := range strings.Split(viper.GetString("AUTH_BACKENDS"), ",") {
+		if backend = strings.ToLower(strings.TrimSpace(backend)); backend != "" {
+			backends = append(backends, backend)
+		}
+	}
+	if len(backends) == 0 {
+		return []string{"database"}
+	}
+	return backends
+}
+
+// LDAPConfig configures the LDAP authentication backend. Users bind as
+// BindDNTemplate with %s replaced by the identifier they signed in with, then
+// their entry is found below SearchBase with SearchFilter. GroupRoles maps
+// the groups listed in the entry's GroupsAttribute to the names of roles.
+type LDAPConfig struct {
+	URL                string
+	StartTLS           bool
+	BindDNTemplate     string
+	SearchBase         string
+	SearchFilter       string
+	UsernameAttribute  string
+	EmailAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+	GroupsAttribute    string
+	GroupRoles         []GroupRole
+	Timeout            time.Duration
+}
+
+// GroupRole gives members of the group Group the role Role.
+type GroupRole struct {
+	Group string
+	Role  string
+}
+
+func GetLDAPConfig() LDAPConfig {
+	cfg := LDAPConfig{
+		URL:                viper.GetString("LDAP_URL"),
+		StartTLS:           viper.GetBool("LDAP_START_TLS"),
+		BindDNTemplate:     viper.GetString("LDAP_BIND_DN_TEMPLATE"),
+		SearchBase:         viper.GetString("LDAP_SEARCH_BASE"),
+		SearchFilter:       viper.GetString("LDAP_SEARCH_FILTER"),
+		UsernameAttribute:  viper.GetString("LDAP_ATTRIBUTE_USERNAME"),
+		EmailAttribute:     viper.GetString("LDAP_ATTRIBUTE_EMAIL"),
+		FirstNameAttribute: viper.GetString("LDAP_ATTRIBUTE_FIRST_NAME"),
+		LastNameAttribute:  viper.GetString("LDAP_ATTRIBUTE_LAST_NAME"),
+		GroupsAttribute:    viper.GetString("LDAP_ATTRIBUTE_GROUPS"),
+		Timeout:            viper.GetDuration("LDAP_TIMEOUT"),
+	}
+	if cfg.URL == "" || cfg.BindDNTemplate == "" || cfg.SearchBase == "" {
+		log.Fatal("LDAP_URL, LDAP_BIND_DN_TEMPLATE and LDAP_SEARCH_BASE must be set")
+	}
+	if cfg.SearchFilter == "" {
+		cfg.SearchFilter = "(uid=%s)"
+	}
+	if cfg.UsernameAttribute == "" {
+		cfg.UsernameAttribute = "uid"
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+	if cfg.FirstNameAttribute == "" {
+		cfg.FirstNameAttribute = "givenName"
+	}
+	if cfg.LastNameAttribute == "" {
+		cfg.LastNameAttribute = "sn"
+	}
+	if cfg.GroupsAttribute == "" {
+		cfg.GroupsAttribute = "memberOf"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	cfg.GroupRoles = getGroupRoles("LDAP_GROUP_ROLES")
+	return cfg
+}
+
+// getGroupRoles reads group role mappings written as "<group>=<role>;...".
+// Groups may be DNs, which contain "=" themselves, so the role follows the
+// last one.
+func getGroupRoles(key string) []GroupRole {
+	var mappings []GroupRole
+	for _, mapping := range strings.Split(viper.GetString(key), ";") {
+		if mapping = strings.TrimSpace(mapping); mapping == "" {
+			continue
+		}
+		i := strings.LastIndex(mapping, "=")
+		if i <= 0 {
+			log.Fatalf("Invalid %s entry %q", key, mapping)
+		}
+		group, role := strings.TrimSpace(mapping[:i]), strings.TrimSpace(mapping[i+1:])
+		if group == "" || role == "" {
+			log.Fatalf("Invalid %s entry %q", key, mapping)
+		}
+		mappings = append(mappings, GroupRole{Group: group, Role: role})
+	}
+	return mappings
+}
+
+// FederationProvider is an upstream OpenID Connect provider users can sign in
+// with. TrustEmail treats its email addresses as verified even when the
+// provider does not send the email_verified claim.
+type FederationProvider struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	TrustEmail   bool
+}
+
+// GetFederationProviders returns the providers listed in FEDERATION_PROVIDERS,
+// each configured by FEDERATION_<NAME>_* variables.
+func GetFederationProviders() []FederationProvider {
+	var providers []FederationProvider
+	for _, name := range strings.Split(viper.GetString("FEDERATION_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "FEDERATION_" + strings.ToUpper(name) + "_"
+
+		provider := FederationProvider{
+			Name:         name,
+			DisplayName:  viper.GetString(prefix + "DISPLAY_NAME"),
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(viper.GetString(prefix + "SCOPES")),
+			TrustEmail:   viper.GetBool(prefix + "TRUST_EMAIL"),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Fatalf("%sISSUER and %sCLIENT_ID must be set", prefix, prefix)
+		}
+		if provider.DisplayName == "" {
+			provider.DisplayName = name
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// GetFederationCallbackURL returns the redirect URI registered with the
+// provider, which is served by this service.
+func GetFederationCallbackURL(name string) string {
+	return strings.TrimRight(GetOIDCIssuer(), "/") + "/login/oidc/" + name + "/callback"
+}
+
+// SAMLConfig configures the SAML service provider. The Attribute fields name
+// the assertion attributes mapped onto users. GroupRoles maps the groups in
+// GroupsAttribute to the names of roles.
+type SAMLConfig struct {
+	EntityID           string
+	IDPMetadataURL     string
+	IDPMetadataFile    string
+	CertFile           string
+	KeyFile            string
+	AllowIDPInitiated  bool
+	EmailAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+	GroupsAttribute    string
+	GroupRoles         []GroupRole
+}
+
+// IsSAMLEnabled reports whether IdP metadata is configured.
+func IsSAMLEnabled() bool {
+	return viper.GetString("SAML_IDP_METADATA_URL") != "" || viper.GetString("SAML_IDP_METADATA_FILE") != ""
+}
+
+func GetSAMLConfig() SAMLConfig {
+	cfg := SAMLConfig{
+		EntityID:           viper.GetString("SAML_SP_ENTITY_ID"),
+		IDPMetadataURL:     viper.GetString("SAML_IDP_METADATA_URL"),
+		IDPMetadataFile:    viper.GetString("SAML_IDP_METADATA_FILE"),
+		CertFile:           viper.GetString("SAML_SP_CERT_FILE"),
+		KeyFile:            viper.GetString("SAML_SP_KEY_FILE"),
+		AllowIDPInitiated:  viper.GetBool("SAML_ALLOW_IDP_INITIATED"),
+		EmailAttribute:     viper.GetString("SAML_ATTRIBUTE_EMAIL"),
+		FirstNameAttribute: viper.GetString("SAML_ATTRIBUTE_FIRST_NAME"),
+		LastNameAttribute:  viper.GetString("SAML_ATTRIBUTE_LAST_NAME"),
+		GroupsAttribute:    viper.GetString("SAML_ATTRIBUTE_GROUPS"),
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		log.Fatal("SAML_SP_CERT_FILE and SAML_SP_KEY_FILE must be set")
+	}
+	if cfg.EntityID == "" {
+		cfg.EntityID = GetSAMLMetadataURL()
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "email"
+	}
+	if cfg.FirstNameAttribute == "" {
+		cfg.FirstNameAttribute = "firstName"
+	}
+	if cfg.LastNameAttribute == "" {
+		cfg.LastNameAttribute = "lastName"
+	}
+	if cfg.GroupsAttribute == "" {
+		cfg.GroupsAttribute = "groups"
+	}
+	cfg.GroupRoles = getGroupRoles("SAML_GROUP_ROLES")
+	return cfg
+}
+
+func GetSAMLMetadataURL() string {
+	return strings.TrimRight(GetOIDCIssuer(), "/") + "/saml/metadata"
+}
+
+func GetSAMLACSURL() string {
+	return strings.TrimRight(GetOIDCIssuer(), "/") + "/saml/acs"
+}
+
+func GetMailDriver() string {
+	driver := viper.GetString("MAIL_DRIVER")
+	if driver == "" {
+		return "log"
+	}
+	return driver
+}
+
+func GetMailFrom() string {
+	from := viper.GetString("MAIL_FROM")
+	if from == "" {
+		return "no-reply@localhost"
+	}
+	return from
+}
+
+func GetMailFilePath() string {
+	path := viper.GetString("MAIL_FILE_PATH")
+	if path == "" {
+		return "mail.log"
+	}
+	return path
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func GetSMTPConfig() SMTPConfig {
+	cfg := SMTPConfig{
+		Host:     viper.GetString("SMTP_HOST"),
+		Port:     viper.GetString("SMTP_PORT"),
+		Username: viper.GetString("SMTP_USERNAME"),
+		Password: viper.GetString("SMTP_PASSWORD"),
+	}
+	if cfg.Host == "" {
+		log.Fatal("SMTP_HOST not set")
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	return cfg
+}
+
+func IsEmailVerificationRequired() bool {
+	return viper.GetBool("REQUIRE_EMAIL_VERIFICATION")
+}
+
+func GetEmailVerificationTTL() time.Duration {
+	ttl := viper.GetDuration("EMAIL_VERIFICATION_TTL")
+	if ttl <= 0 {
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+// GetEmailVerificationURL returns the page verification emails link to. The
+// token is appended as the token query parameter.
+func GetEmailVerificationURL() string {
+	verificationURL := viper.GetString("EMAIL_VERIFICATION_URL")
+	if verificationURL == "" {
+		return GetOIDCIssuer() + "/verify-email"
+	}
+	return verificationURL
+}
+
+func GetPasswordResetTTL() time.Duration {
+	ttl := viper.GetDuration("PASSWORD_RESET_TTL")
+	if ttl <= 0 {
+		return time.Hour
+	}
+	return ttl
+}
+
+// GetPasswordResetURL returns the page password reset emails link to. The
+// token is appended as the token query parameter.
+func GetPasswordResetURL() string {
+	resetURL := viper.GetString("PASSWORD_RESET_URL")
+	if resetURL == "" {
+		return GetOIDCIssuer() + "/reset-password"
+	}
+	return resetURL
+}
+
+func GetMagicLinkTTL() time.Duration {
+	ttl := viper.GetDuration("MAGIC_LINK_TTL")
+	if ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+// GetMagicLinkURL returns the page magic link emails link to. The token is
+// appended as the token query parameter.
+func GetMagicLinkURL() string {
+	magicLinkURL := viper.GetString("MAGIC_LINK_URL")
+	if magicLinkURL == "" {
+		return GetOIDCIssuer() + "/login/magic-link/callback"
+	}
+	return magicLinkURL
+}
+
+// GetMagicLinkMaxPerHour returns how many magic links an account is sent per
+// hour at most.
+func GetMagicLinkMaxPerHour() int {
+	max := viper.GetInt("MAGIC_LINK_MAX_PER_HOUR")
+	if max <= 0 {
+		return 5
+	}
+	return max
+}
+
+// GetLockoutThresholds returns how many failed logins lock an account and a
+// client IP.
+func GetLockoutThresholds() (int, int) {
+	account := viper.GetInt("LOCKOUT_MAX_ATTEMPTS")
+	if account <= 0 {
+		account = 5
+	}
+	ip := viper.GetInt("LOCKOUT_IP_MAX_ATTEMPTS")
+	if ip <= 0 {
+		ip = 20
+	}
+	return account, ip
+}
+
+// GetLockoutDuration returns how long the first lock lasts and the limit the
+// doubling durations of consecutive locks are capped at.
+func GetLockoutDuration() (time.Duration, time.Duration) {
+	base := viper.GetDuration("LOCKOUT_BASE_DURATION")
+	if base <= 0 {
+		base = time.Minute
+	}
+	max := viper.GetDuration("LOCKOUT_MAX_DURATION")
+	if max <= 0 {
+		max = time.Hour
+	}
+	return base, max
+}
+
+// GetLockoutWindow returns how long failed logins are remembered.
+func GetLockoutWindow() time.Duration {
+	window := viper.GetDuration("LOCKOUT_WINDOW")
+	if window <= 0 {
+		return 15 * time.Minute
+	}
+	return window
+}
+
+func IsRateLimitEnabled() bool {
+	if !viper.IsSet("RATE_LIMIT_ENABLED") {
+		return true
+	}
+	return viper.GetBool("RATE_LIMIT_ENABLED")
+}
+
+func GetRateLimitStore() string {
+	store := viper.GetString("RATE_LIMIT_STORE")
+	if store == "" {
+		return "memory"
+	}
+	return store
+}
+
+// RateLimit allows Limit requests per Period for each key of the kind named
+// by Key: "ip", "user" or "client". A zero Limit disables the limit.
+type RateLimit struct {
+	Limit  int
+	Period time.Duration
+	Key    string
+}
+
+// GetRateLimit reads the policy RATE_LIMIT_<NAME>, written as
+// "<limit>/<period>[:<key>]" such as "10/1m:ip", falling back to def when it
+// is unset or invalid.
+func GetRateLimit(name string, def RateLimit) RateLimit {
+	envName := "RATE_LIMIT_" + strings.ToUpper(name)
+	spec := viper.GetString(envName)
+	if spec == "" {
+		return def
+	}
+
+	limit := def
+	if rate, key, ok := strings.Cut(spec, ":"); ok {
+		spec, limit.Key = rate, key
+	}
+	count, period, ok := strings.Cut(spec, "/")
+	if !ok {
+		log.Printf("Invalid %s %q, using the default", envName, spec)
+		return def
+	}
+	var err error
+	if limit.Limit, err = strconv.Atoi(count); err != nil || limit.Limit < 0 {
+		log.Printf("Invalid %s %q, using the default", envName, spec)
+		return def
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		log.Printf("Invalid %s %q, using the default", envName, spec)
+		return def
+	}
+	if limit.Key != "ip" && limit.Key != "user" && limit.Key != "client" {
+		log.Printf("Invalid %s key %q, using the default", envName, limit.Key)
+		return def
+	}
+	return limit
+}
+
+// GetTrustedProxies reads TRUSTED_PROXIES, a comma separated list of the IPs
+// or CIDR ranges of the reverse proxies in front of the service. Client IPs
+// are only read from X-Forwarded-For when the request came through one.
+func GetTrustedProxies() []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, proxy := range strings.Split(viper.GetString("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				log.Fatalf("Invalid TRUSTED_PROXIES entry %q", proxy)
+			}
+			bits := 8 * len(ip)
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES entry %q", proxy)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies
+}
+
+// PasswordPolicy describes the rules new passwords must follow.
+// BreachedCorpus is the path of a local Have I Been Pwned corpus; it is
+// empty when the breached password check is disabled.
+type PasswordPolicy struct {
+	MinLength        int
+	MaxBytes         int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	DisallowUserInfo bool
+	BreachedCorpus   string
+}
+
+func GetPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{
+		MinLength:        viper.GetInt("PASSWORD_MIN_LENGTH"),
+		MaxBytes:         viper.GetInt("PASSWORD_MAX_BYTES"),
+		RequireUpper:     viper.GetBool("PASSWORD_REQUIRE_UPPER"),
+		RequireLower:     viper.GetBool("PASSWORD_REQUIRE_LOWER"),
+		RequireDigit:     viper.GetBool("PASSWORD_REQUIRE_DIGIT"),
+		RequireSymbol:    viper.GetBool("PASSWORD_REQUIRE_SYMBOL"),
+		DisallowUserInfo: true,
+		BreachedCorpus:   viper.GetString("PASSWORD_BREACHED_CORPUS"),
+	}
+	if policy.MinLength <= 0 {
+		policy.MinLength = 8
+	}
+	// bcrypt only hashes the first 72 bytes of a password; Argon2id has no
+	// such limit, but very long passwords are still refused to bound the work.
+	if policy.MaxBytes <= 0 {
+		policy.MaxBytes = 1024
+		if GetPasswordHashing().Algorithm == "bcrypt" {
+			policy.MaxBytes = 72
+		}
+	}
+	if viper.IsSet("PASSWORD_DISALLOW_USER_INFO") {
+		policy.DisallowUserInfo = viper.GetBool("PASSWORD_DISALLOW_USER_INFO")
+	}
+	return policy
+}
+
+// PasswordHashing selects the algorithm new password hashes are made with.
+// Argon2Memory is in KiB.
+type PasswordHashing struct {
+	Algorithm         string
+	Argon2Memory      uint32
+	Argon2Time        uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+}
+
+func GetPasswordHashing() PasswordHashing {
+	hashing := PasswordHashing{
+		Algorithm:         strings.ToLower(viper.GetString("PASSWORD_HASH_ALGORITHM")),
+		Argon2Memory:      viper.GetUint32("ARGON2_MEMORY"),
+		Argon2Time:        viper.GetUint32("ARGON2_TIME"),
+		Argon2Parallelism: uint8(viper.GetUint("ARGON2_PARALLELISM")),
+		BcryptCost:        viper.GetInt("BCRYPT_COST"),
+	}
+	if hashing.Algorithm == "" {
+		hashing.Algorithm = "argon2id"
+	}
+	if hashing.Argon2Memory == 0 {
+		hashing.Argon2Memory = 64 * 1024
+	}
+	if hashing.Argon2Time == 0 {
+		hashing.Argon2Time = 3
+	}
+	if hashing.Argon2Parallelism == 0 {
+		hashing.Argon2Parallelism = 2
+	}
+	if hashing.BcryptCost == 0 {
+		hashing.BcryptCost = 10
+	}
+	return hashing
+}
+
 func GetAllowedOrigins() []string {
 	allowedOrigins := viper.GetString("ALLOWED_ORIGINS")
 	if allowedOrigins == "" {
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"platform-service/internal/config"
 	"platform-service/internal/models"
+	"slices"
+	"strconv"
+	"strings"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
@@ -30,10 +33,178 @@ func InitDB() error {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	err = DB.AutoMigrate(&models.User{})
+	err = DB.AutoMigrate(
+		&models.User{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.SigningKey{},
+		&models.OAuthClient{},
+		&models.AuthorizationCode{},
+		&models.TOTPCredential{},
+		&models.RecoveryCode{},
+		&models.MFAChallenge{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnSession{},
+		&models.PasswordResetToken{},
+		&models.MagicLinkToken{},
+		&models.LoginThrottle{},
+		&models.RateLimitBucket{},
+		&models.Session{},
+		&models.PersonalAccessToken{},
+		&models.AuditLog{},
+		&models.FederatedIdentity{},
+		&models.FederatedLoginState{},
+		&models.SAMLRequest{},
+		&models.SAMLAssertion{},
+		&models.Permission{},
+		&models.Role{},
+	)
 	if err != nil {
 		return fmt.Errorf("failed to auto-migrate: %w", err)
 	}
 
+	if err := backfillNormalizedIdentifiers(); err != nil {
+		return fmt.Errorf("failed to normalize user identifiers: %w", err)
+	}
+
+	if err := seedAdminRole(); err != nil {
+		return fmt.Errorf("failed to seed roles: %w", err)
+	}
+
+	if err := migrateLegacyRoles(); err != nil {
+		return fmt.Errorf("failed to migrate user roles: %w", err)
+	}
+
 	return nil
 }
+
+// backfillNormalizedIdentifiers fills the normalized username and email of
+// users created before those columns existed.
+func backfillNormalizedIdentifiers() error {
+	var pending int64
+	err := DB.Unscoped().Model(&models.User{}).
+		Where("normalized_username IS NULL OR normalized_email IS NULL").
+		Count(&pending).Error
+	if err != nil || pending == 0 {
+		return err
+	}
+	if err := checkIdentifierCollisions(); err != nil {
+		return err
+	}
+
+	var users []models.User
+	return DB.Unscoped().
+		Where("normalized_username IS NULL OR normalized_email IS NULL").
+		FindInBatches(&users, 100, func(tx *gorm.DB, batch int) error {
+			for i := range users {
+				err := DB.Unscoped().Model(&users[i]).UpdateColumns(map[string]interface{}{
+					"normalized_username": models.NormalizeIdentifier(users[i].Username),
+					"normalized_email":    models.NormalizeIdentifier(users[i].Email),
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// checkIdentifierCollisions fails with a list of the users whose usernames or
+// email addresses differ only by case or surrounding spaces. Their normalized
+// identifiers would violate the unique indexes, and which account keeps the
+// identifier is for an admin to decide.
+func checkIdentifierCollisions() error {
+	var users []models.User
+	if err := DB.Unscoped().Select("id", "username", "email").Order("id").Find(&users).Error; err != nil {
+		return err
+	}
+
+	usernames := make(map[string][]string)
+	emails := make(map[string][]string)
+	for _, user := range users {
+		id := strconv.FormatUint(uint64(user.ID), 10)
+		username := models.NormalizeIdentifier(user.Username)
+		usernames[username] = append(usernames[username], id)
+		email := models.NormalizeIdentifier(user.Email)
+		emails[email] = append(emails[email], id)
+	}
+
+	var collisions []string
+	for username, ids := range usernames {
+		if len(ids) > 1 {
+			collisions = append(collisions, fmt.Sprintf("username %q is used by users %s", username, strings.Join(ids, ", ")))
+		}
+	}
+	for email, ids := range emails {
+		if len(ids) > 1 {
+			collisions = append(collisions, fmt.Sprintf("email %q is used by users %s", email, strings.Join(ids, ", ")))
+		}
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+	slices.Sort(collisions)
+	return fmt.Errorf("usernames and email addresses must be unique regardless of case; rename or delete all but one of these users: %s",
+		strings.Join(collisions, "; "))
+}
+
+// seedAdminRole creates the built-in permissions and grants all of them to
+// the admin role.
+func seedAdminRole() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var permissions []models.Permission
+		for name, description := range models.BuiltinPermissions {
+			permission := models.Permission{Name: name}
+			err := tx.Where(&permission).Attrs(models.Permission{Description: description}).
+				FirstOrCreate(&permission).Error
+			if err != nil {
+				return err
+			}
+			permissions = append(permissions, permission)
+		}
+
+		role := models.Role{Name: models.RoleAdmin}
+		err := tx.Where(&role).Attrs(models.Role{Description: "Full access to the admin API"}).
+			FirstOrCreate(&role).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&role).Association("Permissions").Append(permissions)
+	})
+}
+
+// migrateLegacyRoles moves the values of the role column, which roles
+// replaced, into roles and then drops the column. Every value other than
+// "user", including those that directory group mappings used to write, becomes
+// a role of that name, which admins then grant permissions to.
+func migrateLegacyRoles() error {
+	if !DB.Migrator().HasColumn(&models.User{}, "role") {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var names []string
+		err := tx.Unscoped().Model(&models.User{}).Distinct().Where("role <> '' AND role <> ?", "user").
+			Pluck("role", &names).Error
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			role := models.Role{Name: name}
+			err := tx.Where(&role).Attrs(models.Role{Description: "Migrated from the legacy role column"}).
+				FirstOrCreate(&role).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Exec(
+				"INSERT INTO user_roles (user_id, role_id) SELECT id, ? FROM users WHERE role = ?",
+				role.ID, name,
+			).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().DropColumn(&models.User{}, "role")
+	})
+}
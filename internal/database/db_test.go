@@ -0,0 +1,140 @@
+package database
+
+import (
+	"path/filepath"
+	"platform-service/internal/models"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// legacyUser is the users table before normalized identifiers were added.
+type legacyUser struct {
+	gorm.Model
+	UID       string `gorm:"type:char(36);uniqueIndex;not null"`
+	Username  string `gorm:"uniqueIndex;not null;size:50"`
+	Password  string `gorm:"not null"`
+	Email     string `gorm:"uniqueIndex;not null"`
+	Role      string `gorm:"default:'user';not null"`
+	Status    string `gorm:"default:'active';not null"`
+	LastLogin time.Time
+}
+
+func (legacyUser) TableName() string {
+	return "users"
+}
+
+// setupLegacyDB creates a database holding the given users in the legacy
+// schema and points the configuration at it.
+func setupLegacyDB(t *testing.T, users ...legacyUser) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&legacyUser{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range users {
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	for key, value := range map[string]string{"DB_DRIVER": "sqlite", "DB_CONNECTION_STRING": path} {
+		previous := viper.GetString(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, previous) })
+	}
+	t.Cleanup(func() {
+		if DB == nil {
+			return
+		}
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestInitDBBackfillsNormalizedIdentifiers(t *testing.T) {
+	setupLegacyDB(t, legacyUser{UID: "uid-1", Username: "Alice", Password: "hash", Email: " Alice@Example.com"})
+
+	if err := InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	var normalized struct {
+		NormalizedUsername string
+		NormalizedEmail    string
+	}
+	if err := DB.Table("users").Select("normalized_username", "normalized_email").Take(&normalized).Error; err != nil {
+		t.Fatal(err)
+	}
+	if normalized.NormalizedUsername != "alice" || normalized.NormalizedEmail != "alice@example.com" {
+		t.Errorf("normalized identifiers = %+v, want alice and alice@example.com", normalized)
+	}
+}
+
+func TestInitDBReportsIdentifiersDifferingOnlyByCase(t *testing.T) {
+	setupLegacyDB(t,
+		legacyUser{UID: "uid-1", Username: "Alice", Password: "hash", Email: "alice@example.com"},
+		legacyUser{UID: "uid-2", Username: "alice", Password: "hash", Email: "bob@example.com"},
+		legacyUser{UID: "uid-3", Username: "bob", Password: "hash", Email: "Bob@Example.com"},
+	)
+
+	err := InitDB()
+	if err == nil {
+		t.Fatal("InitDB succeeded, want the collisions reported")
+	}
+	for _, want := range []string{`username "alice" is used by users 1, 2`, `email "bob@example.com" is used by users 2, 3`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("InitDB = %v, want it to report %s", err, want)
+		}
+	}
+}
+
+func TestInitDBMigratesLegacyRoles(t *testing.T) {
+	setupLegacyDB(t,
+		legacyUser{UID: "uid-1", Username: "alice", Password: "hash", Email: "alice@example.com", Role: "admin"},
+		legacyUser{UID: "uid-2", Username: "bob", Password: "hash", Email: "bob@example.com", Role: "user"},
+		legacyUser{UID: "uid-3", Username: "carol", Password: "hash", Email: "carol@example.com", Role: "editor"},
+	)
+
+	if err := InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	if DB.Migrator().HasColumn(&models.User{}, "role") {
+		t.Error("the legacy role column was not dropped")
+	}
+
+	var users []models.User
+	if err := DB.Preload("Roles").Order("id").Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{models.RoleAdmin}, {}, {"editor"}}
+	for i, user := range users {
+		if !slices.Equal(user.RoleNames(), want[i]) {
+			t.Errorf("%s has roles %v, want %v", user.Username, user.RoleNames(), want[i])
+		}
+	}
+
+	// The migration only runs while the column exists.
+	if err := InitDB(); err != nil {
+		t.Fatalf("InitDB again: %v", err)
+	}
+	var count int64
+	if err := DB.Table("user_roles").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("%d role grants after a second InitDB, want 2", count)
+	}
+}
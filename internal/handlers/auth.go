@@ -1,36 +1,53 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"platform-service/internal/config"
 	"platform-service/internal/database"
 	"platform-service/internal/models"
+	"platform-service/internal/passwords"
+	"platform-service/internal/services"
 	"platform-service/internal/utils"
+	"strconv"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RegisterRequest struct {
 	Username     string `json:"username" validate:"required,min=3,max=50"`
-	Password     string `json:"password" validate:"required,min=6"`
+	Password     string `json:"password" validate:"required"`
 	Email        string `json:"email" validate:"required,email"`
 	FirstName    string `json:"first_name"`
 	LastName     string `json:"last_name"`
 	ProfileImage string `json:"profile_image"`
 }
 
+// LoginRequest identifies the user by username or email address. Username is
+// still accepted for clients that predate Identifier.
 type LoginRequest struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Identifier string `json:"identifier"`
+	Username   string `json:"username"`
+	Password   string `json:"password" validate:"required"`
+}
+
+func (r LoginRequest) identifier() string {
+	if r.Identifier != "" {
+		return r.Identifier
+	}
+	return r.Username
 }
 
 type LoginResponse struct {
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
-	User      UserInfo  `json:"user"`
+	Token            string          `json:"token"`
+	ExpiresAt        time.Time       `json:"expires_at"`
+	RefreshToken     string          `json:"refresh_token"`
+	RefreshExpiresAt time.Time       `json:"refresh_expires_at"`
+	IDToken          string          `json:"id_token,omitempty"`
+	User             models.SafeUser `json:"user"`
 }
 
 type UserInfo struct {
@@ -39,7 +56,7 @@ type UserInfo struct {
 	Email     string    `json:"email"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
-	Role      string    `json:"role"`
+	Roles     []string  `json:"roles"`
 	LastLogin time.Time `json:"last_login"`
 }
 
@@ -51,88 +68,134 @@ func Register(c echo.Context) error {
 		})
 	}
 
-	var existingUser models.User
-	if err := database.DB.Where("LOWER(username) = LOWER(?) OR LOWER(email) = LOWER(?)",
-		req.Username, req.Email).First(&existingUser).Error; err == nil {
-		return c.JSON(http.StatusConflict, map[string]string{
-			"error": "Username or email already exists",
-		})
-	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to hash password",
-		})
+	if err := passwords.Check(req.Password, req.Username, req.Email); err != nil {
+		return passwordPolicyError(c, err)
 	}
 
-	user := &models.User{
+	user, err := services.RegisterUser(services.NewUser{
 		Username:     req.Username,
-		Password:     string(hashedPassword),
+		Password:     req.Password,
 		Email:        req.Email,
 		FirstName:    req.FirstName,
 		LastName:     req.LastName,
 		ProfileImage: req.ProfileImage,
-		Role:         "user",
-		Status:       "active",
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
-		LastIP:       c.RealIP(),
-		UID:          uuid.NewString(),
-	}
-
-	tx := database.DB.Begin()
-	if err := tx.Create(user).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create user",
+		IP:           c.RealIP(),
+	})
+	if errors.Is(err, services.ErrUserExists) {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Username or email already exists",
 		})
-	}
-
-	if err := tx.Commit().Error; err != nil {
+	} else if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to commit transaction",
+			"error": "Failed to create user",
 		})
 	}
 
 	return c.JSON(http.StatusCreated, map[string]interface{}{
-		"message": "User registered successfully",
-		"userId":  user.UID,
+		"message":                   "User registered successfully",
+		"userId":                    user.UID,
+		"emailVerificationRequired": user.IsPendingVerification(),
 	})
 }
 
 func Login(c echo.Context) error {
-	user := new(models.User)
-	if err := c.Bind(user); err != nil {
+	var req LoginRequest
+	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
 	}
 
-	storedUser := new(models.User)
-	result := database.DB.Where("username = ?", user.Username).First(storedUser)
-	if result.Error == gorm.ErrRecordNotFound {
+	storedUser, err := services.Authenticate(req.identifier(), req.Password, c.RealIP())
+	var lockout *services.LockoutError
+	switch {
+	case errors.As(err, &lockout):
+		return lockedResponse(c, lockout)
+	case errors.Is(err, services.ErrInvalidCredentials):
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid credentials"})
-	} else if result.Error != nil {
+	case errors.Is(err, services.ErrAccountInactive):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	case errors.Is(err, services.ErrEmailNotVerified):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Email address is not verified"})
+	case errors.Is(err, services.ErrLDAPEmailMissing):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Directory entry has no email address"})
+	case errors.Is(err, services.ErrFederationLinkUnverified):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "An account with this email address exists. Verify the address or sign in with your password first"})
+	case errors.Is(err, services.ErrBackendUnavailable):
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Sign-in is temporarily unavailable. Try again later"})
+	case err != nil:
+		log.Printf("Error authenticating user: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
 	}
 
-	if !storedUser.IsActive() {
-		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	if services.MFARequired(storedUser) {
+		return beginMFAChallenge(c, storedUser)
 	}
+	return completeLogin(c, storedUser)
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(user.Password)); err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid credentials"})
-	}
-	expiredAt := time.Now().Add(time.Hour * 24)
-	token, err := utils.GenerateJWT(storedUser.UID, storedUser.Username, storedUser.Role, expiredAt)
+func lockedResponse(c echo.Context, lockout *services.LockoutError) error {
+	retryAfter := lockout.RetryAfterSeconds()
+	c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	return c.JSON(http.StatusLocked, map[string]interface{}{
+		"error":       "Too many failed login attempts",
+		"retry_after": retryAfter,
+	})
+}
+
+// completeLogin issues the access and refresh tokens for an authenticated user
+// and records the login.
+func completeLogin(c echo.Context, user *models.User) error {
+	response, err := newLogin(c, user)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
 	}
+	return c.JSON(http.StatusOK, response)
+}
 
-	storedUser.UpdateLastLogin(c.RealIP())
-	database.DB.Save(storedUser)
+func newLogin(c echo.Context, user *models.User) (LoginResponse, error) {
+	session, refreshToken, refresh, err := services.StartSession(user, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		return LoginResponse{}, err
+	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"token": token,
-		"user":  storedUser.ToSafeUser(),
-	})
+	accessToken, expiresAt, err := generateAccessToken(user, session.SessionID)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	idToken, err := utils.GenerateIDToken(user, config.GetIDTokenAudience(), "", "", time.Now(), expiresAt)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	user.UpdateLastLogin(c.RealIP())
+	database.DB.Omit(clause.Associations).Save(user)
+
+	response := newLoginResponse(user, accessToken, expiresAt, refreshToken, refresh)
+	response.IDToken = idToken
+	return response, nil
+}
+
+// generateAccessToken issues an access token carrying the user's current
+// roles and permissions, bound to the session unless sessionID is empty.
+func generateAccessToken(user *models.User, sessionID string) (string, time.Time, error) {
+	if err := services.LoadUserRoles(user); err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(config.GetAccessTokenTTL())
+	opts := []utils.TokenOption{utils.WithRoles(user)}
+	if sessionID != "" {
+		opts = append(opts, utils.WithSession(sessionID))
+	}
+	token, err := utils.GenerateJWT(user.UID, user.Username, expiresAt, opts...)
+	return token, expiresAt, err
+}
+
+func newLoginResponse(user *models.User, accessToken string, expiresAt time.Time, refreshToken string, refresh *models.RefreshToken) LoginResponse {
+	return LoginResponse{
+		Token:            accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refresh.ExpiresAt,
+		User:             user.ToSafeUser(),
+	}
 }
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"platform-service/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// VerifyEmail confirms the email address of the token's user.
+func VerifyEmail(c echo.Context) error {
+	var req VerifyEmailRequest
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	user, err := services.VerifyEmail(req.Token)
+	if errors.Is(err, services.ErrVerificationTokenInvalid) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired verification token"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify email"})
+	}
+	if err := services.LoadUserRoles(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch roles"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Email verified successfully",
+		"user":    user.ToSafeUser(),
+	})
+}
+
+// ResendVerificationEmail sends a new verification link. It always answers
+// 202 and sends in the background so that the response does not reveal
+// whether the address is registered.
+func ResendVerificationEmail(c echo.Context) error {
+	var req ResendVerificationRequest
+	if err := c.Bind(&req); err != nil || req.Email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	go func(email string) {
+		if err := services.ResendVerificationEmail(email); err != nil {
+			log.Printf("Error resending verification email: %v", err)
+		}
+	}(req.Email)
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "If the address belongs to an unverified account, a verification email has been sent",
+	})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"platform-service/internal/config"
+	"platform-service/internal/services"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// federationStateCookie binds a federated login to the browser that started
+// it, so that a callback URL cannot be used to sign someone else in.
+const federationStateCookie = "federation_state"
+
+type FederationProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	LoginURL    string `json:"login_url"`
+}
+
+// ListFederationProviders returns the identity providers users can sign in
+// with.
+func ListFederationProviders(c echo.Context) error {
+	providers := services.ListFederationProviders()
+	response := make([]FederationProviderInfo, 0, len(providers))
+	for _, provider := range providers {
+		response = append(response, FederationProviderInfo{
+			Name:        provider.Name,
+			DisplayName: provider.DisplayName,
+			LoginURL:    "/login/oidc/" + provider.Name,
+		})
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// BeginFederatedLogin redirects the browser to the identity provider.
+func BeginFederatedLogin(c echo.Context) error {
+	authURL, state, err := services.BeginFederatedLogin(c.Param("provider"))
+	if errors.Is(err, services.ErrFederationProviderNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Identity provider not found"})
+	} else if err != nil {
+		log.Printf("Error starting federated login with %s: %v", c.Param("provider"), err)
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "Identity provider is unavailable"})
+	}
+
+	c.SetCookie(newFederationStateCookie(c, state, int(services.FederatedLoginStateTTL.Seconds())))
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// FederatedLoginCallback completes a login at the identity provider and
+// responds like Login.
+func FederatedLoginCallback(c echo.Context) error {
+	cookie, cookieErr := c.Cookie(federationStateCookie)
+	c.SetCookie(newFederationStateCookie(c, "", -1))
+
+	if c.QueryParam("error") != "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Identity provider login failed"})
+	}
+	state := c.QueryParam("state")
+	if cookieErr != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired login state"})
+	}
+
+	user, err := services.FinishFederatedLogin(c.Request().Context(), c.Param("provider"), state, c.QueryParam("code"), c.RealIP())
+	switch {
+	case errors.Is(err, services.ErrFederationProviderNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Identity provider not found"})
+	case errors.Is(err, services.ErrFederationStateInvalid):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired login state"})
+	case errors.Is(err, services.ErrFederationFailed):
+		log.Printf("Error completing federated login with %s: %v", c.Param("provider"), err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Identity provider login failed"})
+	case errors.Is(err, services.ErrFederationEmailUnverified):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Identity provider did not return a verified email address"})
+	case errors.Is(err, services.ErrFederationLinkUnverified):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "An account with this email address exists. Verify the address or sign in with your password first"})
+	case err != nil:
+		log.Printf("Error completing federated login with %s: %v", c.Param("provider"), err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to complete login"})
+	}
+
+	if !user.IsActive() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	}
+	if services.MFARequired(user) {
+		return beginMFAChallenge(c, user)
+	}
+	return completeLogin(c, user)
+}
+
+func newFederationStateCookie(c echo.Context, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     federationStateCookie,
+		Value:    value,
+		Path:     "/login/oidc/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.GetOIDCIssuer(), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ImpersonateResponse struct {
+	Token     string          `json:"token"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	User      models.SafeUser `json:"user"`
+}
+
+type AuditLogEntry struct {
+	ID        uint            `json:"id"`
+	Action    string          `json:"action"`
+	ActorID   string          `json:"actor_id,omitempty"`
+	SubjectID string          `json:"subject_id,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ImpersonateUser lets an admin act as the given user with a short-lived
+// access token. It requires an admin login; personal access tokens cannot
+// impersonate.
+func ImpersonateUser(c echo.Context) error {
+	if currentClaims(c).IsPersonalAccessToken() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Personal access tokens cannot impersonate users"})
+	}
+
+	var req ImpersonateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	admin, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	target, err := findUserByUID(c.Param("uid"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	token, expiresAt, err := services.StartImpersonation(admin, target, req.Reason, c.RealIP())
+	if errors.Is(err, services.ErrImpersonationForbidden) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "User cannot be impersonated"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to impersonate user"})
+	}
+
+	return c.JSON(http.StatusOK, ImpersonateResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      target.ToSafeUser(),
+	})
+}
+
+// EndImpersonation revokes the impersonation token used for the request.
+func EndImpersonation(c echo.Context) error {
+	err := services.EndImpersonation(currentClaims(c), c.RealIP())
+	if errors.Is(err, services.ErrNotImpersonating) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Token is not an impersonation token"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to end impersonation"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListAuditLogs lets an admin read the audit log, optionally filtered by
+// action and by user.
+func ListAuditLogs(c echo.Context) error {
+	limit := 100
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+		}
+		limit = min(parsed, 1000)
+	}
+
+	entries, err := services.ListAuditLogs(c.QueryParam("action"), c.QueryParam("user"), limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list audit log"})
+	}
+
+	response := make([]AuditLogEntry, 0, len(entries))
+	for _, entry := range entries {
+		item := AuditLogEntry{
+			ID:        entry.ID,
+			Action:    entry.Action,
+			ActorID:   entry.ActorID,
+			SubjectID: entry.SubjectID,
+			IP:        entry.IP,
+			CreatedAt: entry.CreatedAt,
+		}
+		if entry.Details != "" {
+			item.Details = json.RawMessage(entry.Details)
+		}
+		response = append(response, item)
+	}
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"net/http"
+	"platform-service/internal/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+func GetJWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, utils.PublicJWKS())
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SigningKeyInfo struct {
+	KID       string     `json:"kid"`
+	Algorithm string     `json:"algorithm"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func newSigningKeyInfo(key *models.SigningKey) SigningKeyInfo {
+	return SigningKeyInfo{
+		KID:       key.KID,
+		Algorithm: key.Algorithm,
+		Status:    key.Status,
+		CreatedAt: key.CreatedAt,
+		RetiredAt: key.RetiredAt,
+		ExpiresAt: key.ExpiresAt,
+	}
+}
+
+func ListSigningKeys(c echo.Context) error {
+	keys, err := services.ListSigningKeys()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list signing keys"})
+	}
+
+	infos := make([]SigningKeyInfo, 0, len(keys))
+	for i := range keys {
+		infos = append(infos, newSigningKeyInfo(&keys[i]))
+	}
+	return c.JSON(http.StatusOK, infos)
+}
+
+func RotateSigningKey(c echo.Context) error {
+	key, err := services.RotateSigningKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to rotate signing key"})
+	}
+	return c.JSON(http.StatusCreated, newSigningKeyInfo(key))
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"platform-service/internal/utils"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type UpdateUserStatusRequest struct {
+	Status string `json:"status" validate:"required"`
+}
+
+func currentClaims(c echo.Context) *models.JwtCustomClaims {
+	return c.Get("user").(*jwt.Token).Claims.(*models.JwtCustomClaims)
+}
+
+// Logout revokes the access token used for the request and, when provided,
+// the refresh token family it was issued with, which must belong to the same
+// user.
+func Logout(c echo.Context) error {
+	var req LogoutRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	claims := currentClaims(c)
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Token cannot be revoked"})
+	}
+	if err := services.Revocations.RevokeToken(claims.ID, claims.PrincipalID(), claims.ExpiresAt.Time); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke token"})
+	}
+
+	if req.RefreshToken != "" {
+		user, err := currentUser(c)
+		if err != nil {
+			return userLookupError(c, err)
+		}
+		refresh := new(models.RefreshToken)
+		result := database.DB.Where("token_hash = ?", utils.HashToken(req.RefreshToken)).First(refresh)
+		if result.Error == nil {
+			if refresh.UserID != user.ID {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "Refresh token belongs to another user"})
+			}
+			if err := services.RevokeRefreshTokenFamily(refresh.FamilyID); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke refresh token"})
+			}
+		} else if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke refresh token"})
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// LogoutAll revokes every access and refresh token of the calling user.
+func LogoutAll(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if err := services.RevokeAllUserTokens(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke tokens"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeUserTokens lets an admin revoke every token of the given user.
+func RevokeUserTokens(c echo.Context) error {
+	user, err := findUserByUID(c.Param("uid"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if err := services.RevokeAllUserTokens(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke tokens"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UnlockUser lets an admin lift a lockout caused by failed logins.
+func UnlockUser(c echo.Context) error {
+	user, err := findUserByUID(c.Param("uid"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if err := services.UnlockAccount(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to unlock user"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UpdateUserStatus lets an admin change a user's status to one of
+// models.UserStatuses. Deactivating an account revokes all of its tokens.
+func UpdateUserStatus(c echo.Context) error {
+	var req UpdateUserStatusRequest
+	if err := c.Bind(&req); err != nil || req.Status == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+	if !slices.Contains(models.UserStatuses, req.Status) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Status must be one of " + strings.Join(models.UserStatuses, ", ")})
+	}
+
+	user, err := findUserByUID(c.Param("uid"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	user.SetStatus(req.Status)
+	if err := database.DB.Save(user).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update user"})
+	}
+
+	if !user.IsActive() {
+		if err := services.RevokeAllUserTokens(user); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke tokens"})
+		}
+	}
+
+	if err := services.LoadUserRoles(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch roles"})
+	}
+	return c.JSON(http.StatusOK, user.ToSafeUser())
+}
+
+var errServicePrincipal = errors.New("token belongs to a service principal")
+
+// currentUser loads the user behind the request's access token.
+func currentUser(c echo.Context) (*models.User, error) {
+	claims := currentClaims(c)
+	if claims.IsService() {
+		return nil, errServicePrincipal
+	}
+	return findUserByUID(claims.UserID)
+}
+
+func findUserByUID(uid string) (*models.User, error) {
+	user := new(models.User)
+	if err := database.DB.Where("uid = ?", uid).First(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func userLookupError(c echo.Context, err error) error {
+	if errors.Is(err, errServicePrincipal) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "User token required"})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"platform-service/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MagicLinkRequest identifies the user by username or email address, like
+// LoginRequest.
+type MagicLinkRequest struct {
+	Identifier string `json:"identifier" validate:"required"`
+}
+
+// RequestMagicLink emails a single-use login link. It always answers 202 and
+// sends in the background so that the response does not reveal whether the
+// account exists.
+func RequestMagicLink(c echo.Context) error {
+	var req MagicLinkRequest
+	if err := c.Bind(&req); err != nil || req.Identifier == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	go func(identifier string, ip string) {
+		if err := services.RequestMagicLink(identifier, ip); err != nil {
+			log.Printf("Error requesting magic link: %v", err)
+		}
+	}(req.Identifier, c.RealIP())
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "If the account exists, a sign-in link has been sent to its email address",
+	})
+}
+
+// MagicLinkCallback redeems the token from a magic link and responds like
+// Login.
+func MagicLinkCallback(c echo.Context) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired sign-in link"})
+	}
+
+	user, err := services.RedeemMagicLink(token, c.RealIP())
+	var lockout *services.LockoutError
+	switch {
+	case errors.As(err, &lockout):
+		return lockedResponse(c, lockout)
+	case errors.Is(err, services.ErrMagicLinkInvalid):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired sign-in link"})
+	case errors.Is(err, services.ErrAccountInactive):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to complete login"})
+	}
+
+	if services.MFARequired(user) {
+		return beginMFAChallenge(c, user)
+	}
+	return completeLogin(c, user)
+}
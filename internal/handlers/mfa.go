@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type MFAChallengeResponse struct {
+	MFARequired           bool      `json:"mfa_required"`
+	MFAEnrollmentRequired bool      `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string    `json:"mfa_token"`
+	ExpiresAt             time.Time `json:"expires_at"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAEnrollmentLoginResponse struct {
+	LoginResponse
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// beginMFAChallenge answers a successful password check of a user that needs
+// a second factor with a challenge token instead of access tokens.
+func beginMFAChallenge(c echo.Context, user *models.User) error {
+	purpose := models.MFAChallengeVerify
+	if services.MFAEnrollmentRequired(user) {
+		purpose = models.MFAChallengeEnroll
+	}
+
+	token, challenge, err := services.CreateMFAChallenge(user, purpose)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create MFA challenge"})
+	}
+
+	return c.JSON(http.StatusAccepted, MFAChallengeResponse{
+		MFARequired:           true,
+		MFAEnrollmentRequired: purpose == models.MFAChallengeEnroll,
+		MFAToken:              token,
+		ExpiresAt:             challenge.ExpiresAt,
+	})
+}
+
+// LoginMFA completes a login with a TOTP or recovery code.
+func LoginMFA(c echo.Context) error {
+	var req MFALoginRequest
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	challenge, user, err := services.FindMFAChallenge(req.MFAToken, models.MFAChallengeVerify)
+	if err != nil {
+		return mfaChallengeError(c, err)
+	}
+	if !user.IsActive() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	}
+
+	if err := services.VerifyMFACode(user, req.Code); err != nil {
+		return mfaCodeError(c, challenge, err)
+	}
+	if err := services.CompleteMFAChallenge(challenge); err != nil {
+		return mfaChallengeError(c, err)
+	}
+
+	return completeLogin(c, user)
+}
+
+// LoginMFAEnroll starts the TOTP enrollment of a user who must enroll before
+// logging in.
+func LoginMFAEnroll(c echo.Context) error {
+	var req MFALoginRequest
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	_, user, err := services.FindMFAChallenge(req.MFAToken, models.MFAChallengeEnroll)
+	if err != nil {
+		return mfaChallengeError(c, err)
+	}
+
+	return beginTOTPEnrollment(c, user)
+}
+
+// LoginMFAEnrollConfirm confirms the enrollment started by LoginMFAEnroll and
+// completes the login.
+func LoginMFAEnrollConfirm(c echo.Context) error {
+	var req MFALoginRequest
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	challenge, user, err := services.FindMFAChallenge(req.MFAToken, models.MFAChallengeEnroll)
+	if err != nil {
+		return mfaChallengeError(c, err)
+	}
+	if !user.IsActive() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	}
+
+	codes, err := services.ConfirmTOTPEnrollment(user, req.Code)
+	if err != nil {
+		return mfaCodeError(c, challenge, err)
+	}
+	if err := services.CompleteMFAChallenge(challenge); err != nil {
+		return mfaChallengeError(c, err)
+	}
+
+	user.MFAEnabled = true
+	response, err := newLogin(c, user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	return c.JSON(http.StatusOK, MFAEnrollmentLoginResponse{
+		LoginResponse: response,
+		RecoveryCodes: codes,
+	})
+}
+
+// EnrollTOTP starts a TOTP enrollment for the authenticated user.
+func EnrollTOTP(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	return beginTOTPEnrollment(c, user)
+}
+
+// ConfirmTOTP enables TOTP for the authenticated user and returns the
+// recovery codes, which are only shown once.
+func ConfirmTOTP(c echo.Context) error {
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	codes, err := services.ConfirmTOTPEnrollment(user, req.Code)
+	if err != nil {
+		return mfaCodeError(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP turns MFA off after checking a current code.
+func DisableTOTP(c echo.Context) error {
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if services.MFAMandatory(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Two-factor authentication is required for this account"})
+	}
+
+	if err := services.VerifyMFACode(user, req.Code); err != nil {
+		return mfaCodeError(c, nil, err)
+	}
+	if err := services.DisableMFA(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to disable two-factor authentication"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a
+// current code.
+func RegenerateRecoveryCodes(c echo.Context) error {
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if err := services.VerifyMFACode(user, req.Code); err != nil {
+		return mfaCodeError(c, nil, err)
+	}
+	codes, err := services.RegenerateRecoveryCodes(user)
+	if err != nil {
+		return mfaCodeError(c, nil, err)
+	}
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func beginTOTPEnrollment(c echo.Context, user *models.User) error {
+	secret, uri, err := services.BeginTOTPEnrollment(user)
+	if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Two-factor authentication is already enabled"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start enrollment"})
+	}
+	return c.JSON(http.StatusOK, TOTPEnrollmentResponse{Secret: secret, OtpauthURI: uri})
+}
+
+func mfaChallengeError(c echo.Context, err error) error {
+	if errors.Is(err, services.ErrMFAChallengeFailed) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired MFA token"})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify MFA token"})
+}
+
+// mfaCodeError maps a failed code check to a response, counting the failure
+// against the challenge when there is one.
+func mfaCodeError(c echo.Context, challenge *models.MFAChallenge, err error) error {
+	switch {
+	case errors.Is(err, services.ErrMFACodeInvalid):
+		if challenge != nil {
+			services.RecordMFAChallengeFailure(challenge)
+		}
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid authentication code"})
+	case errors.Is(err, services.ErrMFANotEnrolled):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Two-factor authentication is not enrolled"})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Two-factor authentication is already enabled"})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify authentication code"})
+	}
+}
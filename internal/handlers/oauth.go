@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	_ "embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"platform-service/internal/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+//go:embed templates/authorize.html
+var authorizeTemplateHTML string
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(authorizeTemplateHTML))
+
+type AuthorizeRequest struct {
+	ResponseType        string `query:"response_type" form:"response_type"`
+	ClientID            string `query:"client_id" form:"client_id"`
+	RedirectURI         string `query:"redirect_uri" form:"redirect_uri"`
+	Scope               string `query:"scope" form:"scope"`
+	State               string `query:"state" form:"state"`
+	CodeChallenge       string `query:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" form:"code_challenge_method"`
+	Nonce               string `query:"nonce" form:"nonce"`
+}
+
+type AuthorizeForm struct {
+	AuthorizeRequest
+	Username string `form:"username"`
+	Password string `form:"password"`
+	Code     string `form:"code"`
+	Action   string `form:"action"`
+}
+
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type authorizePage struct {
+	ClientName string
+	Scopes     []string
+	Request    AuthorizeRequest
+	Username   string
+	Error      string
+}
+
+// Authorize renders the login and consent page of the authorization code
+// flow.
+func Authorize(c echo.Context) error {
+	var req AuthorizeRequest
+	if err := c.Bind(&req); err != nil {
+		return renderAuthorizeError(c, "Invalid authorization request")
+	}
+
+	client, ok, err := validateAuthorizeRequest(c, &req)
+	if !ok {
+		return err
+	}
+
+	return renderAuthorizePage(c, http.StatusOK, client, req, "", "")
+}
+
+// AuthorizeSubmit checks the credentials entered on the consent page and
+// redirects back to the client with an authorization code.
+func AuthorizeSubmit(c echo.Context) error {
+	var form AuthorizeForm
+	if err := c.Bind(&form); err != nil {
+		return renderAuthorizeError(c, "Invalid authorization request")
+	}
+	req := form.AuthorizeRequest
+
+	client, ok, err := validateAuthorizeRequest(c, &req)
+	if !ok {
+		return err
+	}
+
+	if form.Action != "allow" {
+		return redirectAuthorizeError(c, req, "access_denied", "The user denied the request")
+	}
+
+	user, err := services.Authenticate(form.Username, form.Password, c.RealIP())
+	var lockout *services.LockoutError
+	switch {
+	case errors.As(err, &lockout):
+		c.Response().Header().Set("Retry-After", strconv.Itoa(lockout.RetryAfterSeconds()))
+		return renderAuthorizePage(c, http.StatusLocked, client, req, form.Username, "Too many failed login attempts. Try again later")
+	case errors.Is(err, services.ErrInvalidCredentials):
+		return renderAuthorizePage(c, http.StatusUnauthorized, client, req, form.Username, "Invalid credentials")
+	case errors.Is(err, services.ErrAccountInactive):
+		return renderAuthorizePage(c, http.StatusForbidden, client, req, form.Username, "Account is not active")
+	case errors.Is(err, services.ErrEmailNotVerified):
+		return renderAuthorizePage(c, http.StatusForbidden, client, req, form.Username, "Verify your email address before signing in")
+	case errors.Is(err, services.ErrLDAPEmailMissing):
+		return renderAuthorizePage(c, http.StatusForbidden, client, req, form.Username, "Your directory account has no email address")
+	case errors.Is(err, services.ErrFederationLinkUnverified):
+		return renderAuthorizePage(c, http.StatusConflict, client, req, form.Username, "An account with this email address exists. Verify the address or sign in with your password first")
+	case errors.Is(err, services.ErrBackendUnavailable):
+		return renderAuthorizePage(c, http.StatusServiceUnavailable, client, req, form.Username, "Sign-in is temporarily unavailable. Try again later")
+	case err != nil:
+		return redirectAuthorizeError(c, req, "server_error", "Failed to authenticate user")
+	}
+
+	if services.MFAEnrollmentRequired(user) {
+		return renderAuthorizePage(c, http.StatusForbidden, client, req, form.Username, "Set up two-factor authentication in TalentLens before signing in to applications")
+	}
+	if user.MFAEnabled {
+		if form.Code == "" {
+			return renderAuthorizePage(c, http.StatusUnauthorized, client, req, form.Username, "Enter the code from your authenticator app")
+		}
+		err := services.VerifyMFACode(user, form.Code)
+		if errors.Is(err, services.ErrMFACodeInvalid) {
+			return renderAuthorizePage(c, http.StatusUnauthorized, client, req, form.Username, "Invalid authentication code")
+		} else if err != nil {
+			return redirectAuthorizeError(c, req, "server_error", "Failed to verify authentication code")
+		}
+	}
+
+	code, err := services.CreateAuthorizationCode(client, user, req.RedirectURI, req.Scope, req.CodeChallenge, req.Nonce)
+	if err != nil {
+		return redirectAuthorizeError(c, req, "server_error", "Failed to issue authorization code")
+	}
+
+	user.UpdateLastLogin(c.RealIP())
+	database.DB.Omit(clause.Associations).Save(user)
+
+	return redirectAuthorize(c, req, url.Values{"code": {code}})
+}
+
+// validateAuthorizeRequest checks the request and fills in the default scope.
+// When ok is false the response has already been written: an error page if
+// the client or redirect URI cannot be trusted, an OAuth error redirect to the
+// client otherwise.
+func validateAuthorizeRequest(c echo.Context, req *AuthorizeRequest) (*models.OAuthClient, bool, error) {
+	client, err := services.FindOAuthClient(req.ClientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, renderAuthorizeError(c, "Unknown client")
+	} else if err != nil {
+		return nil, false, renderAuthorizeError(c, "Failed to load client")
+	}
+	if !client.HasRedirectURI(req.RedirectURI) {
+		return nil, false, renderAuthorizeError(c, "Invalid redirect URI")
+	}
+
+	if req.Scope == "" {
+		req.Scope = client.Scopes
+	}
+
+	var code, description string
+	switch {
+	case req.ResponseType != "code":
+		code, description = "unsupported_response_type", "Only the code response type is supported"
+	case req.CodeChallenge == "" || req.CodeChallengeMethod != "S256":
+		code, description = "invalid_request", "PKCE with the S256 method is required"
+	case !client.AllowsScope(req.Scope):
+		code, description = "invalid_scope", "The requested scope is not allowed for this client"
+	default:
+		return client, true, nil
+	}
+
+	return nil, false, redirectAuthorizeError(c, *req, code, description)
+}
+
+func renderAuthorizePage(c echo.Context, status int, client *models.OAuthClient, req AuthorizeRequest, username string, message string) error {
+	setAuthorizePageHeaders(c)
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(status)
+	return authorizeTemplate.Execute(c.Response(), authorizePage{
+		ClientName: client.Name,
+		Scopes:     strings.Fields(req.Scope),
+		Request:    req,
+		Username:   username,
+		Error:      message,
+	})
+}
+
+func renderAuthorizeError(c echo.Context, message string) error {
+	setAuthorizePageHeaders(c)
+	return c.HTML(http.StatusBadRequest, "<!DOCTYPE html><title>Authorization error</title><p>"+template.HTMLEscapeString(message)+"</p>")
+}
+
+func setAuthorizePageHeaders(c echo.Context) {
+	header := c.Response().Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Frame-Options", "DENY")
+	header.Set("Content-Security-Policy", "frame-ancestors 'none'")
+}
+
+func redirectAuthorizeError(c echo.Context, req AuthorizeRequest, code string, description string) error {
+	return redirectAuthorize(c, req, url.Values{
+		"error":             {code},
+		"error_description": {description},
+	})
+}
+
+func redirectAuthorize(c echo.Context, req AuthorizeRequest, params url.Values) error {
+	target, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return renderAuthorizeError(c, "Invalid redirect URI")
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	target.RawQuery = query.Encode()
+	return c.Redirect(http.StatusFound, target.String())
+}
+
+// Token is the OAuth 2.0 token endpoint.
+func Token(c echo.Context) error {
+	var req TokenRequest
+	if err := c.Bind(&req); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "Invalid request payload")
+	}
+
+	client, err := authenticateOAuthClient(c, req.ClientID, req.ClientSecret)
+	if errors.Is(err, services.ErrInvalidClient) {
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+	} else if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to authenticate client")
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials:
+		if !client.AllowsGrantType(req.GrantType) {
+			return oauthError(c, http.StatusBadRequest, "unauthorized_client", "The client may not use this grant type")
+		}
+	default:
+		return oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant type")
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode:
+		return authorizationCodeGrant(c, client, req)
+	case models.GrantTypeRefreshToken:
+		return refreshTokenGrant(c, client, req)
+	default:
+		return clientCredentialsGrant(c, client, req)
+	}
+}
+
+// authenticateOAuthClient verifies the client credentials sent with HTTP Basic
+// authentication or as the client_id and client_secret form parameters.
+func authenticateOAuthClient(c echo.Context, clientID string, secret string) (*models.OAuthClient, error) {
+	if username, password, ok := c.Request().BasicAuth(); ok {
+		var err error
+		if clientID, err = url.QueryUnescape(username); err != nil {
+			return nil, services.ErrInvalidClient
+		}
+		if secret, err = url.QueryUnescape(password); err != nil {
+			return nil, services.ErrInvalidClient
+		}
+	}
+	if clientID == "" {
+		return nil, services.ErrInvalidClient
+	}
+	return services.AuthenticateOAuthClient(clientID, secret)
+}
+
+func authorizationCodeGrant(c echo.Context, client *models.OAuthClient, req TokenRequest) error {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "code and code_verifier are required")
+	}
+
+	code, err := services.ExchangeAuthorizationCode(req.Code, client, req.RedirectURI, req.CodeVerifier)
+	if errors.Is(err, services.ErrInvalidGrant) {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+	} else if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to redeem authorization code")
+	}
+
+	user := new(models.User)
+	if err := database.DB.First(user, code.UserID).Error; err != nil || !user.IsActive() {
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+	}
+
+	response, err := newOAuthTokenResponse(client, user, code.Scope, code.Nonce, code.AuthTime)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate token")
+	}
+
+	if models.HasScope(code.Scope, "offline_access") {
+		refreshToken, _, err := services.IssueClientRefreshToken(user.ID, client.ClientID, code.Scope, c.RealIP())
+		if err != nil {
+			return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate refresh token")
+		}
+		response.RefreshToken = refreshToken
+	}
+
+	return oauthTokenJSON(c, response)
+}
+
+func refreshTokenGrant(c echo.Context, client *models.OAuthClient, req TokenRequest) error {
+	if req.RefreshToken == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "refresh_token is required")
+	}
+
+	refreshToken, refresh, err := services.RotateRefreshToken(req.RefreshToken, client.ClientID, c.RealIP())
+	switch {
+	case errors.Is(err, services.ErrRefreshTokenReused),
+		errors.Is(err, services.ErrRefreshTokenInvalid),
+		errors.Is(err, services.ErrRefreshTokenExpired):
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+	case err != nil:
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to refresh token")
+	}
+
+	user := new(models.User)
+	if err := database.DB.First(user, refresh.UserID).Error; err != nil || !user.IsActive() {
+		services.RevokeRefreshTokenFamily(refresh.FamilyID)
+		return oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+	}
+
+	scope := refresh.Scope
+	if req.Scope != "" {
+		for _, s := range strings.Fields(req.Scope) {
+			if !models.HasScope(refresh.Scope, s) {
+				return oauthError(c, http.StatusBadRequest, "invalid_scope", "The requested scope exceeds the granted scope")
+			}
+		}
+		scope = req.Scope
+	}
+
+	response, err := newOAuthTokenResponse(client, user, scope, "", user.LastLogin)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate token")
+	}
+	response.RefreshToken = refreshToken
+	return oauthTokenJSON(c, response)
+}
+
+// clientCredentialsGrant issues a token for the client itself, identifying a
+// service principal rather than a user.
+func clientCredentialsGrant(c echo.Context, client *models.OAuthClient, req TokenRequest) error {
+	if client.IsPublic() {
+		return oauthError(c, http.StatusBadRequest, "unauthorized_client", "Public clients cannot use the client credentials grant")
+	}
+
+	scope := req.Scope
+	if scope == "" {
+		scope = client.Scopes
+	}
+	if !client.AllowsScope(scope) {
+		return oauthError(c, http.StatusBadRequest, "invalid_scope", "The requested scope is not allowed for this client")
+	}
+
+	ttl := config.GetAccessTokenTTL()
+	accessToken, err := utils.GenerateServiceJWT(client.ClientID, client.Name, scope, client.TokenAudience(), time.Now().Add(ttl))
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate token")
+	}
+
+	return oauthTokenJSON(c, &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       scope,
+	})
+}
+
+func newOAuthTokenResponse(client *models.OAuthClient, user *models.User, scope string, nonce string, authTime time.Time) (*OAuthTokenResponse, error) {
+	ttl := config.GetAccessTokenTTL()
+	expiresAt := time.Now().Add(ttl)
+	accessToken, err := utils.GenerateJWT(user.UID, user.Username, expiresAt,
+		utils.WithAudience(client.TokenAudience()), utils.WithScope(scope), utils.WithClientID(client.ClientID))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       scope,
+	}
+	if models.HasScope(scope, "openid") {
+		response.IDToken, err = utils.GenerateIDToken(user, client.ClientID, scope, nonce, authTime, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+func oauthTokenJSON(c echo.Context, response *OAuthTokenResponse) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+	return c.JSON(http.StatusOK, response)
+}
+
+func oauthError(c echo.Context, status int, code string, description string) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	if status == http.StatusUnauthorized {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+	return c.JSON(status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	GrantTypes   []string `json:"grant_types"`
+	Audience     string   `json:"audience"`
+	Public       bool     `json:"public"`
+}
+
+type OAuthClientInfo struct {
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	GrantTypes   []string  `json:"grant_types"`
+	Audience     string    `json:"audience"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newOAuthClientInfo(client *models.OAuthClient) OAuthClientInfo {
+	return OAuthClientInfo{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIList(),
+		Scopes:       models.ScopeList(client.Scopes),
+		GrantTypes:   client.GrantTypeList(),
+		Audience:     client.TokenAudience(),
+		Public:       client.IsPublic(),
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// CreateOAuthClient registers a client. The secret is only returned once.
+// Callers can only grant the permissions they hold, and only admins can grant
+// the admin scope. The openid scope is refused while tokens are signed with a
+// shared secret.
+func CreateOAuthClient(c echo.Context) error {
+	var req CreateOAuthClientRequest
+	if err := c.Bind(&req); err != nil || req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+	for _, uri := range req.RedirectURIs {
+		parsed, err := url.Parse(uri)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Redirect URIs must be absolute URIs without a fragment"})
+		}
+	}
+
+	for _, grantType := range req.GrantTypes {
+		switch grantType {
+		case models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken:
+		case models.GrantTypeClientCredentials:
+			if req.Public {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Public clients cannot use the client credentials grant"})
+			}
+		default:
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unsupported grant type: " + grantType})
+		}
+	}
+
+	client, secret, err := services.CreateOAuthClient(req.Name, req.RedirectURIs, req.Scopes, req.GrantTypes, req.Audience, req.Public, currentClaims(c))
+	if errors.Is(err, services.ErrUnknownScope) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Scopes must be OpenID Connect scopes, admin or existing permissions"})
+	} else if errors.Is(err, services.ErrSymmetricIDTokens) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "The openid scope requires an asymmetric signing key. Set JWT_SIGNING_ALG to an algorithm such as RS256 and rotate the signing key"})
+	} else if errors.Is(err, services.ErrScopeNotHeld) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Clients cannot be granted permissions the caller does not hold"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create client"})
+	}
+
+	info := newOAuthClientInfo(client)
+	info.ClientSecret = secret
+	return c.JSON(http.StatusCreated, info)
+}
+
+func ListOAuthClients(c echo.Context) error {
+	var clients []models.OAuthClient
+	if err := database.DB.Order("created_at").Find(&clients).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list clients"})
+	}
+
+	infos := make([]OAuthClientInfo, 0, len(clients))
+	for i := range clients {
+		infos = append(infos, newOAuthClientInfo(&clients[i]))
+	}
+	return c.JSON(http.StatusOK, infos)
+}
+
+// DeleteOAuthClient removes a client and revokes the refresh tokens issued
+// to it.
+func DeleteOAuthClient(c echo.Context) error {
+	client, err := services.FindOAuthClient(c.Param("client_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Client not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch client"})
+	}
+
+	if err := services.DeleteOAuthClient(client); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete client"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"platform-service/internal/config"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type UserInfoResponse struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+	UpdatedAt         int64  `json:"updated_at,omitempty"`
+}
+
+func GetOpenIDConfiguration(c echo.Context) error {
+	issuer := config.GetOIDCIssuer()
+	return c.JSON(http.StatusOK, OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		UserinfoEndpoint:                  issuer + "/api/userinfo",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  utils.SigningAlgorithms(),
+		ScopesSupported:                   models.OIDCScopes,
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "given_name", "family_name", "preferred_username", "email", "email_verified", "picture",
+		},
+	})
+}
+
+// GetUserInfo returns the OpenID Connect claims of the authenticated user.
+// Tokens issued to OAuth clients only see the claims of the granted scopes.
+func GetUserInfo(c echo.Context) error {
+	claims := currentClaims(c)
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	if !user.IsActive() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	}
+
+	info := UserInfoResponse{Subject: user.UID}
+	if claims.Scope == "" || models.HasScope(claims.Scope, "profile") {
+		info.Name = user.FullName()
+		info.GivenName = user.FirstName
+		info.FamilyName = user.LastName
+		info.PreferredUsername = user.Username
+		info.Picture = user.ProfileImage
+		info.UpdatedAt = user.UpdatedAt.Unix()
+	}
+	if claims.Scope == "" || models.HasScope(claims.Scope, "email") {
+		info.Email = user.Email
+		info.EmailVerified = &user.EmailVerified
+	}
+	return c.JSON(http.StatusOK, info)
+}
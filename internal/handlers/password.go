@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"platform-service/internal/passwords"
+	"platform-service/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// ForgotPassword emails a password reset link. It always answers 202 and
+// sends in the background so that the response does not reveal whether the
+// address is registered.
+func ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil || req.Email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	go func(email string, ip string) {
+		if err := services.RequestPasswordReset(email, ip); err != nil {
+			log.Printf("Error requesting password reset: %v", err)
+		}
+	}(req.Email, c.RealIP())
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "If the address belongs to an account, a password reset email has been sent",
+	})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and
+// signs the user out everywhere.
+func ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	_, err := services.ResetPassword(req.Token, req.Password)
+	var policyErr *passwords.PolicyError
+	switch {
+	case errors.Is(err, services.ErrPasswordResetTokenInvalid):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired password reset token"})
+	case errors.As(err, &policyErr):
+		return passwordPolicyError(c, policyErr)
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to reset password"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Password has been reset"})
+}
+
+// ChangePassword sets a new password for the authenticated user, who has to
+// confirm the current one, and signs the user out everywhere.
+func ChangePassword(c echo.Context) error {
+	var req ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	err = services.ChangePassword(user, req.CurrentPassword, req.NewPassword)
+	var policyErr *passwords.PolicyError
+	switch {
+	case errors.Is(err, services.ErrPasswordIncorrect):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Current password is incorrect"})
+	case errors.Is(err, services.ErrDirectoryManaged):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Password is managed by the directory"})
+	case errors.As(err, &policyErr):
+		return passwordPolicyError(c, policyErr)
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to change password"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// passwordPolicyError reports the rules a new password breaks.
+func passwordPolicyError(c echo.Context, err error) error {
+	var policyErr *passwords.PolicyError
+	if !errors.As(err, &policyErr) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check password"})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":      "Password does not meet the requirements",
+		"violations": policyErr.Violations,
+	})
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type PersonalAccessTokenInfo struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+}
+
+// CreatePersonalAccessTokenResponse is the only response that contains the
+// token itself.
+type CreatePersonalAccessTokenResponse struct {
+	PersonalAccessTokenInfo
+	Token string `json:"token"`
+}
+
+func newPersonalAccessTokenInfo(token *models.PersonalAccessToken) PersonalAccessTokenInfo {
+	return PersonalAccessTokenInfo{
+		ID:         token.ID,
+		Name:       token.Name,
+		Hint:       token.Hint,
+		Scopes:     strings.Fields(token.Scopes),
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		LastUsedIP: token.LastUsedIP,
+	}
+}
+
+// CreatePersonalAccessToken issues a personal access token to the
+// authenticated user. Personal access tokens and tokens issued to OAuth
+// clients cannot create further tokens.
+func CreatePersonalAccessToken(c echo.Context) error {
+	claims := currentClaims(c)
+	if claims.IsPersonalAccessToken() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Personal access tokens cannot create tokens"})
+	}
+	if claims.ClientID != "" {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "OAuth client tokens cannot create tokens"})
+	}
+
+	var req CreatePersonalAccessTokenRequest
+	if err := c.Bind(&req); err != nil || strings.TrimSpace(req.Name) == "" || len(req.Name) > 100 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	raw, token, err := services.CreatePersonalAccessToken(user, strings.TrimSpace(req.Name), req.Scopes, req.ExpiresAt)
+	switch {
+	case errors.Is(err, services.ErrInvalidScope):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid scope"})
+	case errors.Is(err, services.ErrInvalidExpiry):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Expiry must be in the future"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create token"})
+	}
+
+	return c.JSON(http.StatusCreated, CreatePersonalAccessTokenResponse{
+		PersonalAccessTokenInfo: newPersonalAccessTokenInfo(token),
+		Token:                   raw,
+	})
+}
+
+// ListPersonalAccessTokens returns the authenticated user's active tokens
+// without the tokens themselves.
+func ListPersonalAccessTokens(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	tokens, err := services.ListPersonalAccessTokens(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list tokens"})
+	}
+
+	response := make([]PersonalAccessTokenInfo, 0, len(tokens))
+	for i := range tokens {
+		response = append(response, newPersonalAccessTokenInfo(&tokens[i]))
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// RevokePersonalAccessToken revokes one of the authenticated user's tokens.
+func RevokePersonalAccessToken(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Token not found"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	err = services.RevokePersonalAccessToken(user, uint(id))
+	if errors.Is(err, services.ErrPersonalAccessTokenNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Token not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke token"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
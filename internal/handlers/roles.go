@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CreatePermissionRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UserRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Builtin     bool   `json:"builtin"`
+}
+
+type RoleInfo struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	Builtin     bool      `json:"builtin"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type UserRolesInfo struct {
+	UID         string   `json:"uid"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+func newRoleInfo(role *models.Role) RoleInfo {
+	return RoleInfo{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.PermissionNames(),
+		Builtin:     role.IsBuiltin(),
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
+func newUserRolesInfo(user *models.User) UserRolesInfo {
+	return UserRolesInfo{
+		UID:         user.UID,
+		Roles:       user.RoleNames(),
+		Permissions: user.PermissionNames(),
+	}
+}
+
+func ListPermissions(c echo.Context) error {
+	permissions, err := services.ListPermissions()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list permissions"})
+	}
+
+	infos := make([]PermissionInfo, 0, len(permissions))
+	for i := range permissions {
+		infos = append(infos, PermissionInfo{
+			Name:        permissions[i].Name,
+			Description: permissions[i].Description,
+			Builtin:     permissions[i].IsBuiltin(),
+		})
+	}
+	return c.JSON(http.StatusOK, infos)
+}
+
+// CreatePermission adds a permission for roles to grant, typically one
+// checked by an application that consumes our tokens.
+func CreatePermission(c echo.Context) error {
+	var req CreatePermissionRequest
+	if err := c.Bind(&req); err != nil || req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	permission, err := services.CreatePermission(req.Name, req.Description, realUserID(c), c.RealIP())
+	if err != nil {
+		return rbacError(c, err, "Failed to create permission")
+	}
+	return c.JSON(http.StatusCreated, PermissionInfo{
+		Name:        permission.Name,
+		Description: permission.Description,
+		Builtin:     permission.IsBuiltin(),
+	})
+}
+
+// DeletePermission removes a permission that is not built in from every role
+// granting it.
+func DeletePermission(c echo.Context) error {
+	if err := services.DeletePermission(c.Param("name"), realUserID(c), c.RealIP()); err != nil {
+		return rbacError(c, err, "Failed to delete permission")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func ListRoles(c echo.Context) error {
+	roles, err := services.ListRoles()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list roles"})
+	}
+
+	infos := make([]RoleInfo, 0, len(roles))
+	for i := range roles {
+		infos = append(infos, newRoleInfo(&roles[i]))
+	}
+	return c.JSON(http.StatusOK, infos)
+}
+
+func GetRole(c echo.Context) error {
+	role, err := services.FindRole(c.Param("name"))
+	if err != nil {
+		return rbacError(c, err, "Failed to fetch role")
+	}
+	return c.JSON(http.StatusOK, newRoleInfo(role))
+}
+
+func CreateRole(c echo.Context) error {
+	var req RoleRequest
+	if err := c.Bind(&req); err != nil || req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	role, err := services.CreateRole(req.Name, req.Description, req.Permissions, realUserID(c), c.RealIP())
+	if err != nil {
+		return rbacError(c, err, "Failed to create role")
+	}
+	return c.JSON(http.StatusCreated, newRoleInfo(role))
+}
+
+// UpdateRole replaces a role's description and permissions. The built-in
+// admin role cannot be changed.
+func UpdateRole(c echo.Context) error {
+	var req RoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	role, err := services.FindRole(c.Param("name"))
+	if err != nil {
+		return rbacError(c, err, "Failed to fetch role")
+	}
+	if err := services.UpdateRole(role, req.Description, req.Permissions, realUserID(c), c.RealIP()); err != nil {
+		return rbacError(c, err, "Failed to update role")
+	}
+	return c.JSON(http.StatusOK, newRoleInfo(role))
+}
+
+func DeleteRole(c echo.Context) error {
+	role, err := services.FindRole(c.Param("name"))
+	if err != nil {
+		return rbacError(c, err, "Failed to fetch role")
+	}
+	if err := services.DeleteRole(role, realUserID(c), c.RealIP()); err != nil {
+		return rbacError(c, err, "Failed to delete role")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetUserRoles returns a user's roles and the permissions they grant.
+func GetUserRoles(c echo.Context) error {
+	user, err := findUserByUID(c.Param("uid"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	if err := services.LoadUserRoles(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch roles"})
+	}
+	return c.JSON(http.StatusOK, newUserRolesInfo(user))
+}
+
+// SetUserRoles replaces a user's roles. The user's access tokens are revoked
+// so that their next refresh picks up the new permissions.
+func SetUserRoles(c echo.Context) error {
+	var req UserRolesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	user, err := findUserByUID(c.Param("uid"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	if err := services.SetUserRoles(user, req.Roles, realUserID(c), c.RealIP()); err != nil {
+		return rbacError(c, err, "Failed to update roles")
+	}
+	return c.JSON(http.StatusOK, newUserRolesInfo(user))
+}
+
+// realUserID returns the UID of the user behind the request, which is the
+// admin rather than the impersonated user while impersonating, or "" for
+// service principals.
+func realUserID(c echo.Context) string {
+	uid, _ := c.Get("real_user_id").(string)
+	return uid
+}
+
+func rbacError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Role not found"})
+	case errors.Is(err, services.ErrPermissionNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Permission not found"})
+	case errors.Is(err, services.ErrInvalidRBACName):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Names may only contain lowercase letters, digits, '.', '_', ':' and '-'"})
+	case errors.Is(err, services.ErrRoleExists):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Role already exists"})
+	case errors.Is(err, services.ErrPermissionExists):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Permission already exists"})
+	case errors.Is(err, services.ErrRoleBuiltin):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Built-in role cannot be changed"})
+	case errors.Is(err, services.ErrPermissionBuiltin):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Built-in permission cannot be deleted"})
+	case errors.Is(err, services.ErrLastAdmin):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "The last admin cannot lose the admin role"})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"platform-service/internal/config"
+	"platform-service/internal/services"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// samlRequestCookie holds the ID of the authentication request a browser was
+// sent to the IdP with, which the IdP's response must answer. The service
+// also stores the request and accepts only one answer to it.
+const samlRequestCookie = "saml_request"
+
+// SAMLMetadata serves the service provider metadata for the IdP.
+func SAMLMetadata(c echo.Context) error {
+	metadata, err := services.SAMLMetadata()
+	if errors.Is(err, services.ErrSAMLDisabled) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "SAML is not configured"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate metadata"})
+	}
+	return c.Blob(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// BeginSAMLLogin redirects the browser to the IdP.
+func BeginSAMLLogin(c echo.Context) error {
+	redirectURL, requestID, err := services.BeginSAMLLogin()
+	if errors.Is(err, services.ErrSAMLDisabled) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "SAML is not configured"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start SAML login"})
+	}
+
+	c.SetCookie(newSAMLRequestCookie(requestID, int(services.FederatedLoginStateTTL.Seconds())))
+	return c.Redirect(http.StatusFound, redirectURL)
+}
+
+// SAMLAssertionConsumer receives the IdP's response and responds like Login.
+func SAMLAssertionConsumer(c echo.Context) error {
+	var requestIDs []string
+	if cookie, err := c.Cookie(samlRequestCookie); err == nil && cookie.Value != "" {
+		requestIDs = append(requestIDs, cookie.Value)
+	}
+	c.SetCookie(newSAMLRequestCookie("", -1))
+
+	user, err := services.FinishSAMLLogin(c.Request(), requestIDs, c.RealIP())
+	switch {
+	case errors.Is(err, services.ErrSAMLDisabled):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "SAML is not configured"})
+	case errors.Is(err, services.ErrSAMLAssertionInvalid):
+		log.Printf("Error validating SAML assertion: %v", err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid SAML assertion"})
+	case errors.Is(err, services.ErrSAMLEmailMissing):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "SAML assertion has no email address"})
+	case errors.Is(err, services.ErrFederationLinkUnverified):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "An account with this email address exists. Verify the address or sign in with your password first"})
+	case err != nil:
+		log.Printf("Error completing SAML login: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to complete login"})
+	}
+
+	if !user.IsActive() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	}
+	if services.MFARequired(user) {
+		return beginMFAChallenge(c, user)
+	}
+	return completeLogin(c, user)
+}
+
+// The IdP posts its response cross-site, so over HTTPS the cookie must be
+// SameSite=None to be sent along.
+func newSAMLRequestCookie(value string, maxAge int) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     samlRequestCookie,
+		Value:    value,
+		Path:     "/saml/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if strings.HasPrefix(config.GetOIDCIssuer(), "https://") {
+		cookie.Secure = true
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	return cookie
+}
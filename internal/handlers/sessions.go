@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func newSessionInfos(sessions []models.Session, currentSessionID string) []SessionInfo {
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, SessionInfo{
+			ID:         session.SessionID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    currentSessionID != "" && session.SessionID == currentSessionID,
+		})
+	}
+	return infos
+}
+
+// ListSessions returns the devices the authenticated user is signed in on.
+func ListSessions(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	return listSessions(c, user, currentClaims(c).SessionID)
+}
+
+// RevokeSession signs the authenticated user out of one of their sessions.
+func RevokeSession(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	return revokeSession(c, user, c.Param("id"))
+}
+
+// RevokeOtherSessions signs the authenticated user out of every session except
+// the one the request was made with.
+func RevokeOtherSessions(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	current := currentClaims(c).SessionID
+	if current == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Token is not bound to a session"})
+	}
+	if err := services.RevokeOtherSessions(user, current); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke sessions"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListUserSessions lets an admin list the sessions of the given user.
+func ListUserSessions(c echo.Context) error {
+	user, err := findUserByUID(c.Param("uid"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	return listSessions(c, user, "")
+}
+
+// RevokeUserSession lets an admin sign the given user out of a session.
+func RevokeUserSession(c echo.Context) error {
+	user, err := findUserByUID(c.Param("uid"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	return revokeSession(c, user, c.Param("id"))
+}
+
+func listSessions(c echo.Context, user *models.User, currentSessionID string) error {
+	sessions, err := services.ListSessions(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list sessions"})
+	}
+	return c.JSON(http.StatusOK, newSessionInfos(sessions, currentSessionID))
+}
+
+func revokeSession(c echo.Context, user *models.User, sessionID string) error {
+	err := services.RevokeSession(user, sessionID)
+	if errors.Is(err, services.ErrSessionNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Session not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke session"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
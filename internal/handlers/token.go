@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func RefreshToken(c echo.Context) error {
+	var req RefreshTokenRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	refreshToken, refresh, err := services.RotateRefreshToken(req.RefreshToken, "", c.RealIP())
+	switch {
+	case errors.Is(err, services.ErrRefreshTokenReused):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Refresh token has already been used"})
+	case errors.Is(err, services.ErrRefreshTokenInvalid), errors.Is(err, services.ErrRefreshTokenExpired):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid refresh token"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh token"})
+	}
+
+	user := new(models.User)
+	result := database.DB.First(user, refresh.UserID)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		services.RevokeRefreshTokenFamily(refresh.FamilyID)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid refresh token"})
+	} else if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+	}
+
+	if !user.IsActive() {
+		services.RevokeRefreshTokenFamily(refresh.FamilyID)
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	}
+
+	sessionID, err := services.TouchSession(refresh, c.RealIP())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh token"})
+	}
+
+	accessToken, expiresAt, err := generateAccessToken(user, sessionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+
+	return c.JSON(http.StatusOK, newLoginResponse(user, accessToken, expiresAt, refreshToken, refresh))
+}
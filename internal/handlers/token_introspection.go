@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"platform-service/internal/config"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type TokenIntrospectionRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// IntrospectionResponse is the RFC 7662 introspection response. Only Active
+// is set for inactive tokens.
+type IntrospectionResponse struct {
+	Active      bool          `json:"active"`
+	Scope       string        `json:"scope,omitempty"`
+	ClientID    string        `json:"client_id,omitempty"`
+	Username    string        `json:"username,omitempty"`
+	Roles       []string      `json:"roles,omitempty"`
+	Permissions []string      `json:"permissions,omitempty"`
+	TokenType   string        `json:"token_type,omitempty"`
+	Exp         int64         `json:"exp,omitempty"`
+	Iat         int64         `json:"iat,omitempty"`
+	Sub         string        `json:"sub,omitempty"`
+	Aud         []string      `json:"aud,omitempty"`
+	Iss         string        `json:"iss,omitempty"`
+	Jti         string        `json:"jti,omitempty"`
+	Act         *models.Actor `json:"act,omitempty"`
+}
+
+// IntrospectToken is the OAuth 2.0 token introspection endpoint. Only
+// confidential clients, such as API gateways, may introspect tokens.
+func IntrospectToken(c echo.Context) error {
+	var req TokenIntrospectionRequest
+	if err := c.Bind(&req); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "Invalid request payload")
+	}
+
+	client, err := authenticateOAuthClient(c, req.ClientID, req.ClientSecret)
+	if errors.Is(err, services.ErrInvalidClient) || (err == nil && client.IsPublic()) {
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+	} else if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to authenticate client")
+	}
+	if req.Token == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+	}
+
+	introspection, err := services.IntrospectToken(client, req.Token, req.TokenTypeHint)
+	if errors.Is(err, services.ErrTokenInactive) {
+		return introspectionJSON(c, &IntrospectionResponse{Active: false})
+	} else if err != nil {
+		log.Printf("Error introspecting token: %v", err)
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to introspect token")
+	}
+
+	if claims := introspection.Claims; claims != nil {
+		response := &IntrospectionResponse{
+			Active:      true,
+			Scope:       claims.Scope,
+			ClientID:    claims.ClientID,
+			Username:    claims.Username,
+			Roles:       claims.Roles,
+			Permissions: claims.Permissions,
+			TokenType:   "Bearer",
+			Sub:         claims.PrincipalID(),
+			Aud:         claims.Audience,
+			Iss:         claims.Issuer,
+			Jti:         claims.ID,
+			Act:         claims.Actor,
+		}
+		if claims.ExpiresAt != nil {
+			response.Exp = claims.ExpiresAt.Unix()
+		}
+		if claims.IssuedAt != nil {
+			response.Iat = claims.IssuedAt.Unix()
+		}
+		return introspectionJSON(c, response)
+	}
+
+	refresh, user := introspection.RefreshToken, introspection.User
+	return introspectionJSON(c, &IntrospectionResponse{
+		Active:      true,
+		Scope:       refresh.Scope,
+		ClientID:    refresh.ClientID,
+		Username:    user.Username,
+		Roles:       user.RoleNames(),
+		Permissions: user.PermissionNames(),
+		TokenType:   services.TokenTypeHintRefreshToken,
+		Exp:         refresh.ExpiresAt.Unix(),
+		Iat:         refresh.CreatedAt.Unix(),
+		Sub:         user.UID,
+		Iss:         config.GetOIDCIssuer(),
+	})
+}
+
+// RevokeToken is the OAuth 2.0 token revocation endpoint. Clients may only
+// revoke tokens issued to them; other tokens are ignored.
+func RevokeToken(c echo.Context) error {
+	var req TokenIntrospectionRequest
+	if err := c.Bind(&req); err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "Invalid request payload")
+	}
+
+	client, err := authenticateOAuthClient(c, req.ClientID, req.ClientSecret)
+	if errors.Is(err, services.ErrInvalidClient) {
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+	} else if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", "Failed to authenticate client")
+	}
+	if req.Token == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+	}
+
+	if err := services.RevokeClientToken(client, req.Token, req.TokenTypeHint); err != nil {
+		log.Printf("Error revoking token: %v", err)
+		return oauthError(c, http.StatusServiceUnavailable, "temporarily_unavailable", "Failed to revoke token")
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.NoContent(http.StatusOK)
+}
+
+func introspectionJSON(c echo.Context, response *IntrospectionResponse) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, response)
+}
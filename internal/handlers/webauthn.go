@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type PasskeyCeremonyResponse struct {
+	Session string      `json:"session"`
+	Options interface{} `json:"options"`
+}
+
+type PasskeyRegistrationRequest struct {
+	Session    string          `json:"session" validate:"required"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type PasskeyLoginRequest struct {
+	Session    string          `json:"session" validate:"required"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type PasskeyInfo struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	BackupEligible bool       `json:"backup_eligible"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+func newPasskeyInfo(credential *models.WebAuthnCredential) PasskeyInfo {
+	return PasskeyInfo{
+		ID:             credential.CredentialID,
+		Name:           credential.Name,
+		BackupEligible: credential.BackupEligible,
+		CreatedAt:      credential.CreatedAt,
+		LastUsedAt:     credential.LastUsedAt,
+	}
+}
+
+// BeginPasskeyRegistration returns the options the browser needs to create a
+// passkey for the authenticated user.
+func BeginPasskeyRegistration(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	options, session, err := services.BeginPasskeyRegistration(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start passkey registration"})
+	}
+	return c.JSON(http.StatusOK, PasskeyCeremonyResponse{Session: session, Options: options})
+}
+
+// FinishPasskeyRegistration stores the passkey created by the browser.
+func FinishPasskeyRegistration(c echo.Context) error {
+	var req PasskeyRegistrationRequest
+	if err := c.Bind(&req); err != nil || req.Session == "" || len(req.Credential) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	credential, err := services.FinishPasskeyRegistration(user, req.Session, req.Name, req.Credential)
+	if err != nil {
+		return passkeyError(c, err)
+	}
+	return c.JSON(http.StatusCreated, newPasskeyInfo(credential))
+}
+
+// ListPasskeys returns the passkeys of the authenticated user.
+func ListPasskeys(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	credentials, err := services.ListPasskeys(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list passkeys"})
+	}
+
+	response := make([]PasskeyInfo, 0, len(credentials))
+	for i := range credentials {
+		response = append(response, newPasskeyInfo(&credentials[i]))
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// DeletePasskey removes one of the authenticated user's passkeys.
+func DeletePasskey(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if err := services.DeletePasskey(user, c.Param("id")); err != nil {
+		return passkeyError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// BeginPasskeyLogin returns the options the browser needs to sign in with a
+// passkey. The options are the same for every account, so no username is
+// taken.
+func BeginPasskeyLogin(c echo.Context) error {
+	options, session, err := services.BeginPasskeyLogin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start passkey login"})
+	}
+	return c.JSON(http.StatusOK, PasskeyCeremonyResponse{Session: session, Options: options})
+}
+
+// FinishPasskeyLogin verifies the passkey assertion and responds like Login.
+// Passkeys require user verification, so no further factor is asked for.
+func FinishPasskeyLogin(c echo.Context) error {
+	var req PasskeyLoginRequest
+	if err := c.Bind(&req); err != nil || req.Session == "" || len(req.Credential) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+	}
+
+	user, err := services.FinishPasskeyLogin(req.Session, req.Credential)
+	if err != nil {
+		return passkeyError(c, err)
+	}
+	if !user.IsActive() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Account is not active"})
+	}
+
+	return completeLogin(c, user)
+}
+
+func passkeyError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrWebAuthnSessionInvalid):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired passkey session"})
+	case errors.Is(err, services.ErrPasskeyInvalid):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Passkey verification failed"})
+	case errors.Is(err, services.ErrPasskeyNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Passkey not found"})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify passkey"})
+	}
+}
@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"fmt"
+	"platform-service/internal/config"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages. MAIL_DRIVER selects the implementation.
+type Sender interface {
+	Send(msg Message) error
+}
+
+var sender Sender = NewLogSender()
+
+// Init configures the sender used by Send from MAIL_DRIVER.
+func Init() error {
+	s, err := NewSender(config.GetMailDriver())
+	if err != nil {
+		return err
+	}
+	sender = s
+	return nil
+}
+
+// NewSender returns the sender for a driver name: "smtp", "file" or "log".
+func NewSender(driver string) (Sender, error) {
+	switch driver {
+	case "smtp":
+		return NewSMTPSender(config.GetSMTPConfig(), config.GetMailFrom()), nil
+	case "file":
+		return NewFileSender(config.GetMailFilePath(), config.GetMailFrom()), nil
+	case "log":
+		return NewLogSender(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER: %q", driver)
+	}
+}
+
+// Send delivers a message with the configured sender.
+func Send(msg Message) error {
+	return sender.Send(msg)
+}
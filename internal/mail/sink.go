@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"log"
+	"os"
+	"sync"
+)
+
+// LogSender writes messages to the application log instead of sending them.
+// It is meant for local development.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(msg Message) error {
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender appends messages to a file, which lets tests and local setups
+// read the links that would have been emailed.
+type FileSender struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+func NewFileSender(path string, from string) *FileSender {
+	return &FileSender{path: path, from: from}
+}
+
+func (s *FileSender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(formatMessage(s.from, msg), "\r\n\r\n"...)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"platform-service/internal/config"
+	"strings"
+	"time"
+)
+
+// SMTPSender delivers messages through an SMTP server. Authentication is
+// only attempted when a username is configured; net/smtp upgrades the
+// connection with STARTTLS when the server offers it.
+type SMTPSender struct {
+	config config.SMTPConfig
+	from   string
+}
+
+func NewSMTPSender(cfg config.SMTPConfig, from string) *SMTPSender {
+	return &SMTPSender{config: cfg, from: from}
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	addr := net.JoinHostPort(s.config.Host, s.config.Port)
+
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	if err := smtp.SendMail(addr, auth, s.from, []string{msg.To}, formatMessage(s.from, msg)); err != nil {
+		return fmt.Errorf("sending mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// formatMessage renders msg as an RFC 5322 message with CRLF line endings.
+func formatMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -1,32 +1,126 @@
 package internal_middleware
 
 import (
+	"errors"
 	"net/http"
 	"platform-service/internal/models"
+	"platform-service/internal/services"
+	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
+// BearerAuth authenticates personal access tokens and hands every other
+// bearer token to jwtAuth. A personal access token is turned into the same
+// claims a JWT carries, so AuthMiddleware and the handlers treat both alike.
+func BearerAuth(jwtAuth echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withJWT := jwtAuth(next)
+		return func(c echo.Context) error {
+			raw, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || !strings.HasPrefix(raw, models.PersonalAccessTokenPrefix) {
+				return withJWT(c)
+			}
+
+			user, token, err := services.AuthenticatePersonalAccessToken(raw, c.RealIP())
+			if errors.Is(err, services.ErrPersonalAccessTokenInvalid) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired personal access token")
+			} else if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify personal access token")
+			}
+
+			c.Set("user", &jwt.Token{
+				Valid: true,
+				Claims: &models.JwtCustomClaims{
+					UserID:        user.UID,
+					Username:      user.Username,
+					Roles:         user.RoleNames(),
+					Permissions:   user.PermissionNames(),
+					TokenUse:      models.TokenUsePersonalAccess,
+					Scope:         token.Scopes,
+					PrincipalType: models.PrincipalUser,
+					RegisteredClaims: jwt.RegisteredClaims{
+						Subject:  user.UID,
+						IssuedAt: jwt.NewNumericDate(time.Now()),
+					},
+				},
+			})
+			return next(c)
+		}
+	}
+}
+
 func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		user := c.Get("user").(*jwt.Token)
 		claims := user.Claims.(*models.JwtCustomClaims)
 
+		if services.Revocations.IsRevoked(claims) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Token has been revoked")
+		}
+		if claims.IsPersonalAccessToken() && !allowsMethod(claims.Scope, c.Request().Method) {
+			return echo.NewHTTPError(http.StatusForbidden, "Token scope does not allow this request")
+		}
+
+		// user_id is the effective user. While an admin impersonates them,
+		// real_user_id is the admin, and otherwise the same user.
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		if claims.IsImpersonation() {
+			c.Set("real_user_id", claims.Actor.Subject)
+			c.Set("real_username", claims.Actor.Username)
+		} else {
+			c.Set("real_user_id", claims.UserID)
+			c.Set("real_username", claims.Username)
+		}
+		if claims.IsService() {
+			c.Set("principal_type", models.PrincipalService)
+			c.Set("client_id", claims.ClientID)
+		} else {
+			c.Set("principal_type", models.PrincipalUser)
+		}
 
 		return next(c)
 	}
 }
 
-func AdminAuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+// RejectImpersonation keeps admins impersonating a user from changing the
+// user's credentials, sessions and tokens.
+func RejectImpersonation(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		user := c.Get("user").(*jwt.Token)
-		claims := user.Claims.(*models.JwtCustomClaims)
-		if claims.Role != "admin" {
-			return echo.NewHTTPError(http.StatusForbidden, "Admin access required")
+		claims := c.Get("user").(*jwt.Token).Claims.(*models.JwtCustomClaims)
+		if claims.IsImpersonation() {
+			return echo.NewHTTPError(http.StatusForbidden, "Not allowed while impersonating")
 		}
 		return next(c)
 	}
 }
+
+// allowsMethod reports whether personal access token scopes allow a request:
+// read allows safe methods and write or admin allow every method.
+func allowsMethod(scope string, method string) bool {
+	if models.HasScope(scope, models.ScopeWrite) || models.HasScope(scope, models.ScopeAdmin) {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return models.HasScope(scope, models.ScopeRead)
+	}
+	return false
+}
+
+// RequirePermission only lets principals holding the permission through.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := c.Get("user").(*jwt.Token)
+			claims := user.Claims.(*models.JwtCustomClaims)
+			if !claims.HasPermission(permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "Permission required: "+permission)
+			}
+			return next(c)
+		}
+	}
+}
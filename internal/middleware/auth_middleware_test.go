@@ -0,0 +1,97 @@
+package internal_middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"platform-service/internal/models"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+func TestRejectImpersonation(t *testing.T) {
+	tests := []struct {
+		name   string
+		actor  *models.Actor
+		status int
+	}{
+		{name: "user", status: http.StatusNoContent},
+		{name: "impersonating admin", actor: &models.Actor{Subject: "admin-uid"}, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/api/sessions/1", nil), httptest.NewRecorder())
+			c.Set("user", &jwt.Token{Valid: true, Claims: &models.JwtCustomClaims{UserID: "user-uid", Actor: tt.actor}})
+
+			err := RejectImpersonation(func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })(c)
+			status := c.Response().Status
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				status = httpErr.Code
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestAllowsMethod(t *testing.T) {
+	tests := []struct {
+		scope  string
+		method string
+		want   bool
+	}{
+		{models.ScopeRead, http.MethodGet, true},
+		{models.ScopeRead, http.MethodHead, true},
+		{models.ScopeRead, http.MethodOptions, true},
+		{models.ScopeRead, http.MethodPost, false},
+		{models.ScopeRead, http.MethodPut, false},
+		{models.ScopeRead, http.MethodDelete, false},
+		{models.ScopeWrite, http.MethodDelete, true},
+		{models.ScopeAdmin, http.MethodPost, true},
+		{"read write", http.MethodPatch, true},
+		{"", http.MethodGet, false},
+		{"openid", http.MethodGet, false},
+	}
+	for _, tt := range tests {
+		if got := allowsMethod(tt.scope, tt.method); got != tt.want {
+			t.Errorf("allowsMethod(%q, %s) = %v, want %v", tt.scope, tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestRequirePermissionWithPersonalAccessToken(t *testing.T) {
+	tests := []struct {
+		scope  string
+		status int
+	}{
+		{models.ScopeRead, http.StatusForbidden},
+		{models.ScopeWrite, http.StatusForbidden},
+		{models.ScopeAdmin, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/admin/users", nil), httptest.NewRecorder())
+		c.Set("user", &jwt.Token{Valid: true, Claims: &models.JwtCustomClaims{
+			UserID:      "admin-uid",
+			Roles:       []string{models.RoleAdmin},
+			Permissions: []string{models.PermissionUsersRead},
+			TokenUse:    models.TokenUsePersonalAccess,
+			Scope:       tt.scope,
+		}})
+
+		err := RequirePermission(models.PermissionUsersRead)(func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })(c)
+		status := c.Response().Status
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			status = httpErr.Code
+		}
+		if status != tt.status {
+			t.Errorf("personal access token with scope %q got %d, want %d", tt.scope, status, tt.status)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package internal_middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"platform-service/internal/config"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimitStore keeps the token buckets. The in-memory store limits each
+// replica on its own; a shared store lets replicas enforce a common limit.
+type RateLimitStore interface {
+	// Take removes a token from the bucket of key, which holds limit.Limit
+	// tokens and refills completely over limit.Period.
+	Take(key string, limit config.RateLimit) (RateLimitResult, error)
+}
+
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of requests that would be allowed right now.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed.
+	RetryAfter time.Duration
+}
+
+// NewRateLimitStore returns the store named by RATE_LIMIT_STORE: "memory" or
+// "database".
+func NewRateLimitStore(kind string) (RateLimitStore, error) {
+	switch kind {
+	case "memory":
+		return NewMemoryRateLimitStore(), nil
+	case "database":
+		return NewDatabaseRateLimitStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE: %q", kind)
+	}
+}
+
+// RateLimiter creates the rate limit middleware of each route. A limiter
+// without a store lets every request through.
+type RateLimiter struct {
+	store RateLimitStore
+}
+
+func NewRateLimiter(store RateLimitStore) *RateLimiter {
+	return &RateLimiter{store: store}
+}
+
+// Limit returns a middleware that applies the named policy. Requests are
+// counted per IP, per authenticated user or per API client depending on
+// limit.Key; the user and client keys fall back to the IP for anonymous
+// requests. If the store fails, requests are let through.
+func (r *RateLimiter) Limit(name string, limit config.RateLimit) echo.MiddlewareFunc {
+	if r.store == nil || limit.Limit == 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return next
+		}
+	}
+
+	keyFunc := rateLimitKeyFunc(limit.Key)
+	policy := fmt.Sprintf("%d;w=%d", limit.Limit, int(limit.Period.Seconds()))
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			result, err := r.store.Take(name+":"+keyFunc(c), limit)
+			if err != nil {
+				log.Printf("Error applying rate limit %s: %v", name, err)
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set("RateLimit-Policy", policy)
+			header.Set("RateLimit-Limit", strconv.Itoa(limit.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				header.Set(echo.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Too many requests"})
+			}
+			return next(c)
+		}
+	}
+}
+
+func rateLimitKeyFunc(key string) func(c echo.Context) string {
+	switch key {
+	case "user":
+		return keyByUser
+	case "client":
+		return keyByClient
+	default:
+		return keyByIP
+	}
+}
+
+// IPExtractor returns how the client IP is found: the peer address, or, for
+// requests that came through one of trustedProxies, the nearest address in
+// X-Forwarded-For that is not a trusted proxy. Other peers cannot choose the
+// IP their requests are counted against.
+func IPExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range trustedProxies {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+func keyByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// keyByUser uses the principal set by AuthMiddleware.
+func keyByUser(c echo.Context) string {
+	if clientID, ok := c.Get("client_id").(string); ok && clientID != "" {
+		return "client:" + clientID
+	}
+	if userID, ok := c.Get("user_id").(string); ok && userID != "" {
+		return "user:" + userID
+	}
+	return keyByIP(c)
+}
+
+// keyByClient uses the authenticated service principal set by
+// AuthMiddleware. Client IDs sent to the token endpoint are not authenticated
+// yet when the limit applies, so those requests are counted per IP; otherwise
+// a new client_id would get a new bucket.
+func keyByClient(c echo.Context) string {
+	if clientID, ok := c.Get("client_id").(string); ok && clientID != "" {
+		return "client:" + clientID
+	}
+	return keyByIP(c)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// takeToken refills a bucket that had tokens at refilledAt and takes one
+// token from it. It returns the new level together with the result.
+func takeToken(tokens float64, refilledAt time.Time, limit config.RateLimit, now time.Time) (float64, RateLimitResult) {
+	capacity := float64(limit.Limit)
+	perToken := limit.Period / time.Duration(limit.Limit)
+
+	tokens = math.Min(capacity, tokens+float64(now.Sub(refilledAt))/float64(perToken))
+
+	var result RateLimitResult
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration((capacity - tokens) * float64(perToken))
+	return tokens, result
+}
+
+// rateLimitBucketExpiry returns when a bucket at the given level is full
+// again and no longer needs to be stored.
+func rateLimitBucketExpiry(tokens float64, limit config.RateLimit, now time.Time) time.Time {
+	perToken := limit.Period / time.Duration(limit.Limit)
+	return now.Add(time.Duration((float64(limit.Limit) - tokens) * float64(perToken)))
+}
@@ -0,0 +1,119 @@
+package internal_middleware
+
+import (
+	"errors"
+	"log"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const rateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitStore keeps the buckets of this replica in memory. Buckets
+// that have refilled completely are dropped periodically.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens     float64
+	refilledAt time.Time
+	expiresAt  time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit config.RateLimit) (RateLimitResult, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		for k, b := range s.buckets {
+			if now.After(b.expiresAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(limit.Limit), refilledAt: now}
+		s.buckets[key] = b
+	}
+
+	tokens, result := takeToken(b.tokens, b.refilledAt, limit, now)
+	b.tokens = tokens
+	b.refilledAt = now
+	b.expiresAt = rateLimitBucketExpiry(tokens, limit, now)
+	return result, nil
+}
+
+// DatabaseRateLimitStore keeps the buckets in the database so that all
+// replicas share them. Each request locks its bucket row for the update.
+type DatabaseRateLimitStore struct{}
+
+func NewDatabaseRateLimitStore() *DatabaseRateLimitStore {
+	store := &DatabaseRateLimitStore{}
+	go func() {
+		ticker := time.NewTicker(rateLimitSweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := store.Prune(); err != nil {
+				log.Printf("Error pruning rate limit buckets: %v", err)
+			}
+		}
+	}()
+	return store
+}
+
+func (s *DatabaseRateLimitStore) Take(key string, limit config.RateLimit) (RateLimitResult, error) {
+	var result RateLimitResult
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		bucket := models.RateLimitBucket{Identifier: key, Tokens: float64(limit.Limit), RefilledAt: now}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("identifier = ?", key).First(&bucket).Error
+		found := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var tokens float64
+		tokens, result = takeToken(bucket.Tokens, bucket.RefilledAt, limit, now)
+		bucket.Tokens = tokens
+		bucket.RefilledAt = now
+		bucket.ExpiresAt = rateLimitBucketExpiry(tokens, limit, now)
+
+		if found {
+			return tx.Save(&bucket).Error
+		}
+		// A concurrent first request for the same key may have inserted the
+		// row in the meantime, in which case this one overwrites it.
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "identifier"}},
+			DoUpdates: clause.AssignmentColumns([]string{"tokens", "refilled_at", "expires_at"}),
+		}).Create(&bucket).Error
+	})
+	return result, err
+}
+
+// Prune deletes the buckets that have refilled completely.
+func (s *DatabaseRateLimitStore) Prune() error {
+	return database.DB.Where("expires_at < ?", time.Now()).Delete(&models.RateLimitBucket{}).Error
+}
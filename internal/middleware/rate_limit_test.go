@@ -0,0 +1,82 @@
+package internal_middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"platform-service/internal/config"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestTakeToken(t *testing.T) {
+	limit := config.RateLimit{Limit: 10, Period: time.Minute}
+	now := time.Now()
+	tests := []struct {
+		name       string
+		tokens     float64
+		refilledAt time.Time
+		wantTokens float64
+		want       RateLimitResult
+	}{
+		{"full bucket", 10, now, 9, RateLimitResult{Allowed: true, Remaining: 9, Reset: 6 * time.Second}},
+		{"empty bucket", 0, now, 0, RateLimitResult{Remaining: 0, Reset: time.Minute, RetryAfter: 6 * time.Second}},
+		{"half a token refilled", 0, now.Add(-3 * time.Second), 0.5, RateLimitResult{Remaining: 0, Reset: 57 * time.Second, RetryAfter: 3 * time.Second}},
+		{"one token refilled", 0, now.Add(-6 * time.Second), 0, RateLimitResult{Allowed: true, Remaining: 0, Reset: time.Minute}},
+		{"refill capped at the limit", 5, now.Add(-time.Hour), 9, RateLimitResult{Allowed: true, Remaining: 9, Reset: 6 * time.Second}},
+	}
+	for _, tt := range tests {
+		tokens, result := takeToken(tt.tokens, tt.refilledAt, limit, now)
+		if tokens != tt.wantTokens || result != tt.want {
+			t.Errorf("%s: takeToken = %v, %+v, want %v, %+v", tt.name, tokens, result, tt.wantTokens, tt.want)
+		}
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := config.RateLimit{Limit: 3, Period: time.Hour}
+
+	for i := 0; i < 3; i++ {
+		if result, _ := store.Take("login:ip:192.0.2.1", limit); !result.Allowed {
+			t.Fatalf("request %d was limited", i+1)
+		}
+	}
+	result, _ := store.Take("login:ip:192.0.2.1", limit)
+	if result.Allowed || result.RetryAfter <= 0 {
+		t.Errorf("fourth request = %+v, want it limited with a retry time", result)
+	}
+	if result, _ := store.Take("login:ip:192.0.2.2", limit); !result.Allowed {
+		t.Error("request from another IP was limited")
+	}
+}
+
+func TestKeyByIPBehindTrustedProxies(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/24")
+	tests := []struct {
+		name           string
+		trustedProxies []*net.IPNet
+		remoteAddr     string
+		forwardedFor   string
+		want           string
+	}{
+		{"no trusted proxies", nil, "198.51.100.7:4321", "192.0.2.1", "ip:198.51.100.7"},
+		{"untrusted peer", []*net.IPNet{proxies}, "198.51.100.7:4321", "192.0.2.1", "ip:198.51.100.7"},
+		{"trusted proxy", []*net.IPNet{proxies}, "10.0.0.5:4321", "192.0.2.1", "ip:192.0.2.1"},
+		{"spoofed entry before the proxy", []*net.IPNet{proxies}, "10.0.0.5:4321", "203.0.113.9, 192.0.2.1", "ip:192.0.2.1"},
+		{"chain of trusted proxies", []*net.IPNet{proxies}, "10.0.0.5:4321", "192.0.2.1, 10.0.0.6", "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		e := echo.New()
+		e.IPExtractor = IPExtractor(tt.trustedProxies)
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set(echo.HeaderXForwardedFor, tt.forwardedFor)
+
+		if got := keyByIP(e.NewContext(req, httptest.NewRecorder())); got != tt.want {
+			t.Errorf("%s: keyByIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	AuditImpersonationStart = "impersonation.start"
+	AuditImpersonationEnd   = "impersonation.end"
+	AuditRoleCreate         = "role.create"
+	AuditRoleUpdate         = "role.update"
+	AuditRoleDelete         = "role.delete"
+	AuditPermissionCreate   = "permission.create"
+	AuditPermissionDelete   = "permission.delete"
+	AuditUserRolesUpdate    = "user.roles.update"
+)
+
+// AuditLog records a security relevant action. ActorID is the UID of the user
+// who performed it and SubjectID the UID of the user it affected. Details is
+// a JSON object with action specific fields.
+type AuditLog struct {
+	gorm.Model
+	Action    string `gorm:"size:64;index;not null"`
+	ActorID   string `gorm:"type:char(36);index"`
+	SubjectID string `gorm:"type:char(36);index"`
+	IP        string `gorm:"size:45"`
+	Details   string `gorm:"type:text"`
+}
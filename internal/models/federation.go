@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FederatedIdentity links a user to their account at an upstream OpenID
+// Connect provider, identified by the provider's subject.
+type FederatedIdentity struct {
+	gorm.Model
+	UserID      uint   `gorm:"index;not null"`
+	Provider    string `gorm:"size:64;uniqueIndex:idx_federated_identity;not null"`
+	Subject     string `gorm:"size:255;uniqueIndex:idx_federated_identity;not null"`
+	Email       string `gorm:"size:255"`
+	LastLoginAt time.Time
+}
+
+// FederatedLoginState carries the state, nonce and PKCE verifier of a login
+// at an upstream provider from the redirect to the callback.
+type FederatedLoginState struct {
+	gorm.Model
+	StateHash    string    `gorm:"size:64;uniqueIndex;not null"`
+	Provider     string    `gorm:"size:64;not null"`
+	Nonce        string    `gorm:"size:64;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	UsedAt       *time.Time
+}
+
+// SAMLRequest is an authentication request sent to the SAML IdP. A response
+// must answer an unexpired request, and each request is answered only once.
+type SAMLRequest struct {
+	gorm.Model
+	RequestID string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// SAMLAssertion records an accepted SAML assertion until it expires, so that
+// a captured response cannot be replayed.
+type SAMLAssertion struct {
+	ID          uint      `gorm:"primarykey"`
+	AssertionID string    `gorm:"size:255;uniqueIndex;not null"`
+	ExpiresAt   time.Time `gorm:"index;not null"`
+}
@@ -1,10 +1,124 @@
 package models
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"errors"
+	"slices"
 
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	TokenUseAccess = "access"
+	TokenUseID     = "id"
+
+	TokenUseEmailVerification = "email_verification"
+
+	// TokenUsePersonalAccess marks the claims built for a request
+	// authenticated with a personal access token. Such claims are never
+	// signed.
+	TokenUsePersonalAccess = "personal_access"
+
+	PrincipalUser    = "user"
+	PrincipalService = "service"
+)
+
+// JwtCustomClaims are the claims of an access token. Tokens of a service
+// principal have no UserID and identify the OAuth client instead. Tokens
+// issued for a first-party login carry the SessionID, and tokens issued to
+// an OAuth client carry its ClientID. First-party user tokens carry the
+// user's roles and the effective permissions they grant as of the time of
+// issue.
 type JwtCustomClaims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID        string   `json:"user_id"`
+	Username      string   `json:"username"`
+	Roles         []string `json:"roles,omitempty"`
+	Permissions   []string `json:"permissions,omitempty"`
+	TokenUse      string   `json:"token_use,omitempty"`
+	Scope         string   `json:"scope,omitempty"`
+	PrincipalType string   `json:"principal_type,omitempty"`
+	ClientID      string   `json:"client_id,omitempty"`
+	SessionID     string   `json:"sid,omitempty"`
+	Actor         *Actor   `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Actor identifies the admin acting as the token's user while impersonating
+// them, following the act claim of RFC 8693.
+type Actor struct {
+	Subject  string `json:"sub"`
+	Username string `json:"username,omitempty"`
+}
+
+func (c *JwtCustomClaims) IsService() bool {
+	return c.PrincipalType == PrincipalService
+}
+
+// IsPersonalAccessToken reports whether the request was authenticated with a
+// personal access token rather than a JWT.
+func (c *JwtCustomClaims) IsPersonalAccessToken() bool {
+	return c.TokenUse == TokenUsePersonalAccess
+}
+
+// IsImpersonation reports whether an admin is acting as the token's user.
+func (c *JwtCustomClaims) IsImpersonation() bool {
+	return c.Actor != nil
+}
+
+// PrincipalID returns the user UID, or the client ID for service principals.
+func (c *JwtCustomClaims) PrincipalID() string {
+	if c.IsService() {
+		return c.ClientID
+	}
+	return c.UserID
+}
+
+// HasPermission reports whether the principal holds the permission. Service
+// principals hold the permissions granted to them as scopes, or every
+// permission with the admin scope. Tokens users granted to an OAuth client
+// only hold permissions granted as scopes, and personal access tokens only
+// carry the user's permissions with the admin scope.
+func (c *JwtCustomClaims) HasPermission(permission string) bool {
+	if c.IsService() {
+		return HasScope(c.Scope, ScopeAdmin) || HasScope(c.Scope, permission)
+	}
+	if c.ClientID != "" && !HasScope(c.Scope, permission) {
+		return false
+	}
+	if c.IsPersonalAccessToken() && !HasScope(c.Scope, ScopeAdmin) {
+		return false
+	}
+	return slices.Contains(c.Permissions, permission)
+}
+
+// Validate rejects tokens that are signed by us but are not access tokens,
+// such as ID tokens. Tokens issued before token_use existed are accepted.
+func (c *JwtCustomClaims) Validate() error {
+	if c.TokenUse != "" && c.TokenUse != TokenUseAccess {
+		return errors.New("token is not an access token")
+	}
+	return nil
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token.
+type IDTokenClaims struct {
+	Name              string           `json:"name,omitempty"`
+	GivenName         string           `json:"given_name,omitempty"`
+	FamilyName        string           `json:"family_name,omitempty"`
+	PreferredUsername string           `json:"preferred_username,omitempty"`
+	Email             string           `json:"email,omitempty"`
+	EmailVerified     *bool            `json:"email_verified,omitempty"`
+	Picture           string           `json:"picture,omitempty"`
+	Nonce             string           `json:"nonce,omitempty"`
+	AuthTime          *jwt.NumericDate `json:"auth_time,omitempty"`
+	TokenUse          string           `json:"token_use"`
+	jwt.RegisteredClaims
+}
+
+// ActionTokenClaims are the claims of a single-purpose token sent to a user,
+// such as an email verification link. Email binds the token to the address it
+// was sent to.
+type ActionTokenClaims struct {
+	Email    string `json:"email,omitempty"`
+	TokenUse string `json:"token_use"`
 	jwt.RegisteredClaims
 }
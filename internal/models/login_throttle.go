@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LoginThrottleAccount = "account"
+	LoginThrottleIP      = "ip"
+)
+
+// LoginThrottle counts recent failed logins for an account or a client IP.
+// Reaching the threshold locks the identifier until LockedUntil; LockCount makes
+// each consecutive lock last twice as long as the previous one.
+type LoginThrottle struct {
+	gorm.Model
+	Identifier    string `gorm:"size:320;uniqueIndex;not null"`
+	Kind          string `gorm:"size:16;not null"`
+	Failures      int    `gorm:"default:0"`
+	LockCount     int    `gorm:"default:0"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+func (t *LoginThrottle) IsLocked() bool {
+	return t.LockedUntil != nil && time.Now().Before(*t.LockedUntil)
+}
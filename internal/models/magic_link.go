@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MagicLinkToken is a hashed single-use token emailed by /login/magic-link
+// and redeemed by /login/magic-link/callback.
+type MagicLinkToken struct {
+	gorm.Model
+	UserID      uint      `gorm:"index;not null"`
+	TokenHash   string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	UsedAt      *time.Time
+	CreatedByIP string `gorm:"size:45"`
+}
+
+func (t *MagicLinkToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
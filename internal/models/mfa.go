@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MFAChallengeVerify = "verify"
+	MFAChallengeEnroll = "enroll"
+)
+
+// TOTPCredential is a user's authenticator app enrollment. Secret is
+// encrypted at rest and the credential only counts once confirmed.
+// LastUsedStep prevents a code from being replayed.
+type TOTPCredential struct {
+	gorm.Model
+	UserID       uint   `gorm:"uniqueIndex;not null"`
+	Secret       string `gorm:"type:text;not null"`
+	ConfirmedAt  *time.Time
+	LastUsedStep int64 `gorm:"default:0"`
+}
+
+func (t *TOTPCredential) IsConfirmed() bool {
+	return t.ConfirmedAt != nil
+}
+
+// RecoveryCode is a hashed one-time code that replaces a TOTP code when the
+// authenticator is lost.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"size:64;not null"`
+	UsedAt   *time.Time
+}
+
+// MFAChallenge is issued by /login once the password has been checked and is
+// redeemed by /login/mfa with a second factor. Enroll challenges let users
+// that are required to use MFA set it up before their first login.
+type MFAChallenge struct {
+	gorm.Model
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	UserID    uint      `gorm:"index;not null"`
+	Purpose   string    `gorm:"size:16;not null"`
+	Attempts  int       `gorm:"default:0"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (c *MFAChallenge) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// OIDCScopes are the OpenID Connect scopes clients may request. They grant
+// access to the user's profile rather than permissions.
+var OIDCScopes = []string{"openid", "profile", "email", "offline_access"}
+
+// DefaultGrantTypes are allowed for clients registered without explicit
+// grant types.
+var DefaultGrantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+
+// OAuthClient is an application registered to obtain tokens, either on
+// behalf of users or, with the client credentials grant, as a service
+// principal of its own. Public clients have no secret and must use PKCE.
+type OAuthClient struct {
+	gorm.Model
+	ClientID     string `gorm:"size:64;uniqueIndex;not null"`
+	Name         string `gorm:"size:100;not null"`
+	SecretHash   string `gorm:"size:64"`
+	RedirectURIs string `gorm:"type:text"`
+	Scopes       string `gorm:"type:text"`
+	GrantTypes   string `gorm:"size:255"`
+	Audience     string `gorm:"size:255"`
+}
+
+func (c *OAuthClient) IsPublic() bool {
+	return c.SecretHash == ""
+}
+
+func (c *OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIList() {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *OAuthClient) GrantTypeList() []string {
+	if c.GrantTypes == "" {
+		return DefaultGrantTypes
+	}
+	return strings.Fields(c.GrantTypes)
+}
+
+func (c *OAuthClient) AllowsGrantType(grantType string) bool {
+	for _, allowed := range c.GrantTypeList() {
+		if allowed == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScope reports whether every scope in the space separated list was
+// granted to the client.
+func (c *OAuthClient) AllowsScope(scope string) bool {
+	for _, s := range strings.Fields(scope) {
+		if !HasScope(c.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// TokenAudience returns the audience of access tokens issued to the client.
+func (c *OAuthClient) TokenAudience() string {
+	if c.Audience != "" {
+		return c.Audience
+	}
+	return c.ClientID
+}
+
+// AuthorizationCode is a single-use code issued by the authorization
+// endpoint and bound to the PKCE challenge of the request.
+type AuthorizationCode struct {
+	gorm.Model
+	CodeHash      string    `gorm:"size:64;uniqueIndex;not null"`
+	ClientID      string    `gorm:"size:64;index;not null"`
+	UserID        uint      `gorm:"index;not null"`
+	RedirectURI   string    `gorm:"type:text;not null"`
+	Scope         string    `gorm:"type:text"`
+	CodeChallenge string    `gorm:"size:128;not null"`
+	Nonce         string    `gorm:"size:255"`
+	AuthTime      time.Time `gorm:"not null"`
+	ExpiresAt     time.Time `gorm:"not null"`
+	UsedAt        *time.Time
+}
+
+func (a *AuthorizationCode) IsExpired() bool {
+	return time.Now().After(a.ExpiresAt)
+}
+
+// ScopeList splits a space separated scope list.
+func ScopeList(scopes string) []string {
+	list := strings.Fields(scopes)
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// HasScope reports whether the space separated scope list contains scope.
+func HasScope(scopes string, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken is a hashed single-use token emailed by /password/forgot
+// and redeemed by /password/reset.
+type PasswordResetToken struct {
+	gorm.Model
+	UserID      uint      `gorm:"index;not null"`
+	TokenHash   string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	UsedAt      *time.Time
+	CreatedByIP string `gorm:"size:45"`
+}
+
+func (t *PasswordResetToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// PersonalAccessTokenPrefix starts every personal access token so that
+	// they can be told apart from JWTs and found by secret scanners.
+	PersonalAccessTokenPrefix = "pat_"
+
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// PersonalAccessTokenScopes are the scopes a personal access token may be
+// granted. read allows safe requests and write allows every request. admin
+// implies write and, for users with the admin role, allows admin endpoints.
+var PersonalAccessTokenScopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// PersonalAccessToken is a long-lived token a user creates for scripts and
+// integrations. Only the hash of the token is stored; Hint holds its first
+// characters so that users can recognize it.
+type PersonalAccessToken struct {
+	gorm.Model
+	UserID     uint   `gorm:"index;not null"`
+	Name       string `gorm:"size:100;not null"`
+	TokenHash  string `gorm:"size:64;uniqueIndex;not null"`
+	Hint       string `gorm:"size:16"`
+	Scopes     string `gorm:"size:255"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string `gorm:"size:45"`
+	RevokedAt  *time.Time
+}
+
+func (t *PersonalAccessToken) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+func (t *PersonalAccessToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// RateLimitBucket is the token bucket of one rate limit key when limits are
+// shared between replicas through the database. Tokens is the bucket level
+// at RefilledAt; the bucket is full again by ExpiresAt and can be deleted.
+type RateLimitBucket struct {
+	ID         uint      `gorm:"primarykey"`
+	Identifier string    `gorm:"size:320;uniqueIndex;not null"`
+	Tokens     float64   `gorm:"not null"`
+	RefilledAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"index;not null"`
+}
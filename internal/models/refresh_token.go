@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is an opaque, single-use token that can be exchanged for a new
+// access token. Tokens issued from the same login share a FamilyID so that the
+// whole chain can be revoked when a rotated token is presented again. Tokens
+// issued to an OAuth client carry its ClientID and the granted Scope.
+type RefreshToken struct {
+	gorm.Model
+	UserID       uint      `gorm:"index;not null"`
+	FamilyID     string    `gorm:"type:char(36);index;not null"`
+	TokenHash    string    `gorm:"size:64;uniqueIndex;not null"`
+	ClientID     string    `gorm:"size:64;index"`
+	Scope        string    `gorm:"type:text"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	RevokedAt    *time.Time
+	ReplacedByID *uint
+	CreatedByIP  string `gorm:"size:45"`
+}
+
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// IsRotated reports whether the token was exchanged for a successor.
+func (t *RefreshToken) IsRotated() bool {
+	return t.ReplacedByID != nil
+}
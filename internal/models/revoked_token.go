@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevokedToken is an entry of the access token denylist. An entry with a
+// SessionID revokes every token of that session, and an entry with neither a
+// JTI nor a SessionID revokes every token of UserID issued up to CreatedAt.
+// Entries can be pruned once ExpiresAt has passed, as the tokens they cover
+// have expired.
+type RevokedToken struct {
+	gorm.Model
+	JTI       string    `gorm:"size:64;index"`
+	UserID    string    `gorm:"type:char(36);index"`
+	SessionID string    `gorm:"type:char(36);index"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+func (t *RevokedToken) IsUserWide() bool {
+	return t.JTI == "" && t.SessionID == ""
+}
+
+func (t *RevokedToken) IsSessionWide() bool {
+	return t.JTI == "" && t.SessionID != ""
+}
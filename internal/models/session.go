@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is a first-party login on one device. SessionID is also the
+// FamilyID of the session's refresh tokens and the sid claim of its access
+// tokens.
+// LastSeenAt is updated on login and whenever the refresh token is rotated.
+type Session struct {
+	gorm.Model
+	SessionID  string    `gorm:"type:char(36);uniqueIndex;not null"`
+	UserID     uint      `gorm:"index;not null"`
+	DeviceName string    `gorm:"size:100"`
+	UserAgent  string    `gorm:"size:512"`
+	IP         string    `gorm:"size:45"`
+	LastSeenAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+}
+
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
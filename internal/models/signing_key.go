@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SigningKeyStatusCurrent = "current"
+	SigningKeyStatusRetired = "retired"
+)
+
+// SigningKey is a persisted JWT signing key. Material holds the encrypted
+// private key; retired keys only verify tokens until ExpiresAt.
+type SigningKey struct {
+	gorm.Model
+	KID       string `gorm:"size:64;uniqueIndex;not null"`
+	Algorithm string `gorm:"size:16;not null"`
+	Material  string `gorm:"type:text;not null"`
+	Status    string `gorm:"size:16;index;not null"`
+	RetiredAt *time.Time
+	ExpiresAt *time.Time
+}
+
+func (k *SigningKey) IsCurrent() bool {
+	return k.Status == SigningKeyStatusCurrent
+}
@@ -1,47 +1,81 @@
 package models
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+const (
+	UserStatusActive              = "active"
+	UserStatusPendingVerification = "pending_verification"
+	UserStatusDisabled            = "disabled"
+)
+
+// UserStatuses are the statuses a user can have. Only active users can log
+// in.
+var UserStatuses = []string{UserStatusActive, UserStatusPendingVerification, UserStatusDisabled}
+
 type User struct {
 	gorm.Model
-	UID          string    `gorm:"type:char(36);uniqueIndex;not null" json:"uid"`
-	Username     string    `gorm:"uniqueIndex;not null;size:50"`
-	Password     string    `gorm:"not null"`
-	Email        string    `gorm:"uniqueIndex;not null"`
-	FirstName    string    `gorm:"size:50"`
-	LastName     string    `gorm:"size:50"`
-	Role         string    `gorm:"default:'user';not null"`
-	Status       string    `gorm:"default:'active';not null"`
-	LastLogin    time.Time `gorm:"default:null"`
-	LoginCount   int       `gorm:"default:0"`
-	LastIP       string    `gorm:"size:45"`
-	CreatedBy    uint      `gorm:"default:0"`
-	UpdatedBy    uint      `gorm:"default:0"`
-	DeletedBy    uint      `gorm:"default:0"`
-	ProfileImage string    `gorm:"size:255"`
-	CreatedAt    time.Time `gorm:"default:current_timestamp"`
-	UpdatedAt    time.Time `gorm:"default:current_timestamp"`
+	UID                string    `gorm:"type:char(36);uniqueIndex;not null" json:"uid"`
+	Username           string    `gorm:"uniqueIndex;not null;size:50"`
+	Password           string    `gorm:"not null"`
+	Email              string    `gorm:"uniqueIndex;not null"`
+	NormalizedUsername string    `gorm:"size:50;uniqueIndex"`
+	NormalizedEmail    string    `gorm:"uniqueIndex"`
+	FirstName          string    `gorm:"size:50"`
+	LastName           string    `gorm:"size:50"`
+	Status             string    `gorm:"default:'active';not null"`
+	LastLogin          time.Time `gorm:"default:null"`
+	LoginCount         int       `gorm:"default:0"`
+	LastIP             string    `gorm:"size:45"`
+	CreatedBy          uint      `gorm:"default:0"`
+	UpdatedBy          uint      `gorm:"default:0"`
+	DeletedBy          uint      `gorm:"default:0"`
+	ProfileImage       string    `gorm:"size:255"`
+	MFAEnabled         bool      `gorm:"default:false"`
+	EmailVerified      bool      `gorm:"default:false"`
+	CreatedAt          time.Time `gorm:"default:current_timestamp"`
+	UpdatedAt          time.Time `gorm:"default:current_timestamp"`
+
+	// Roles are only set once loaded, see services.LoadUserRoles.
+	Roles []Role `gorm:"many2many:user_roles;constraint:OnDelete:CASCADE"`
 }
 type SafeUser struct {
-	UID          string    `json:"uid"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	FirstName    string    `json:"firstName,omitempty"`
-	LastName     string    `json:"lastName,omitempty"`
-	Role         string    `json:"role"`
-	Status       string    `json:"status"`
-	LastLogin    time.Time `json:"lastLogin"`
-	LoginCount   int       `json:"loginCount"`
-	ProfileImage string    `json:"profileImage,omitempty"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	UID           string    `json:"uid"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	FirstName     string    `json:"firstName,omitempty"`
+	LastName      string    `json:"lastName,omitempty"`
+	Roles         []string  `json:"roles"`
+	Status        string    `json:"status"`
+	LastLogin     time.Time `json:"lastLogin"`
+	LoginCount    int       `json:"loginCount"`
+	ProfileImage  string    `json:"profileImage,omitempty"`
+	MFAEnabled    bool      `json:"mfaEnabled"`
+	EmailVerified bool      `json:"emailVerified"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
 }
 type JSON map[string]interface{}
 
+// NormalizeIdentifier returns the canonical form of a username or email
+// address, which compares case-insensitively.
+func NormalizeIdentifier(identifier string) string {
+	return strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// BeforeSave keeps the normalized username and email, which logins look
+// users up by, in sync with the displayed ones.
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	u.NormalizedUsername = NormalizeIdentifier(u.Username)
+	u.NormalizedEmail = NormalizeIdentifier(u.Email)
+	return nil
+}
+
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.CreatedBy == 0 {
 		u.CreatedBy = 1
@@ -70,11 +104,54 @@ func (u *User) UpdateLastLogin(ip string) {
 }
 
 func (u *User) IsActive() bool {
-	return u.Status == "active"
+	return u.Status == UserStatusActive
+}
+
+// IsPendingVerification reports whether the account waits for its email
+// address to be verified before it can log in.
+func (u *User) IsPendingVerification() bool {
+	return u.Status == UserStatusPendingVerification
 }
 
+// RoleNames returns the names of the user's loaded roles, sorted.
+func (u *User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		names = append(names, role.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// PermissionNames returns the effective permissions granted by the user's
+// loaded roles, sorted and without duplicates.
+func (u *User) PermissionNames() []string {
+	var names []string
+	for i := range u.Roles {
+		names = append(names, u.Roles[i].PermissionNames()...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+func (u *User) HasPermission(permission string) bool {
+	return slices.Contains(u.PermissionNames(), permission)
+}
+
+// IsAdmin reports whether any of the user's loaded roles grants a permission
+// checked by this service, which makes them an admin for MFA and personal
+// access token purposes.
 func (u *User) IsAdmin() bool {
-	return u.Role == "admin"
+	for _, permission := range u.PermissionNames() {
+		if _, ok := BuiltinPermissions[permission]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) FullName() string {
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
 }
 
 func (u *User) SetStatus(status string) {
@@ -83,17 +160,19 @@ func (u *User) SetStatus(status string) {
 
 func (u *User) ToSafeUser() SafeUser {
 	return SafeUser{
-		UID:          u.UID,
-		Username:     u.Username,
-		Email:        u.Email,
-		FirstName:    u.FirstName,
-		LastName:     u.LastName,
-		Role:         u.Role,
-		Status:       u.Status,
-		LastLogin:    u.LastLogin,
-		LoginCount:   u.LoginCount,
-		ProfileImage: u.ProfileImage,
-		CreatedAt:    u.CreatedAt,
-		UpdatedAt:    u.UpdatedAt,
+		UID:           u.UID,
+		Username:      u.Username,
+		Email:         u.Email,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Roles:         u.RoleNames(),
+		Status:        u.Status,
+		LastLogin:     u.LastLogin,
+		LoginCount:    u.LoginCount,
+		ProfileImage:  u.ProfileImage,
+		MFAEnabled:    u.MFAEnabled,
+		EmailVerified: u.EmailVerified,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
 	}
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WebAuthnSessionRegister = "register"
+	WebAuthnSessionLogin    = "login"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user.
+// CredentialID is the base64url encoded credential ID sent by the
+// authenticator and PublicKey is its COSE encoded public key.
+type WebAuthnCredential struct {
+	gorm.Model
+	UserID          uint   `gorm:"index;not null"`
+	CredentialID    string `gorm:"size:1366;uniqueIndex;not null"`
+	PublicKey       []byte `gorm:"not null"`
+	Name            string `gorm:"size:100"`
+	AttestationType string `gorm:"size:32"`
+	Transports      string `gorm:"size:100"`
+	AAGUID          []byte
+	SignCount       uint32 `gorm:"default:0"`
+	CloneWarning    bool   `gorm:"default:false"`
+	BackupEligible  bool   `gorm:"default:false"`
+	BackupState     bool   `gorm:"default:false"`
+	LastUsedAt      *time.Time
+}
+
+// WebAuthnSession holds the challenge of a registration or login ceremony
+// between its begin and finish requests. UserID is zero for passwordless
+// logins, where the user is only known once the authenticator answers.
+type WebAuthnSession struct {
+	gorm.Model
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	UserID    uint      `gorm:"index"`
+	Purpose   string    `gorm:"size:16;not null"`
+	Data      string    `gorm:"type:text;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (s *WebAuthnSession) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
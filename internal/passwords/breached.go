@@ -0,0 +1,87 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedCorpus looks up SHA-1 hashes of breached passwords in a local copy
+// of the Have I Been Pwned corpus. The path is either a directory of range
+// files, named after the first five hex characters of the hash (optionally
+// with a .txt extension) and containing "SUFFIX:COUNT" lines as returned by
+// the range API, or a single file of "HASH:COUNT" lines, which is loaded into
+// memory and therefore only suited to smaller lists.
+type BreachedCorpus struct {
+	dir    string
+	hashes map[string]struct{}
+}
+
+func OpenBreachedCorpus(path string) (*BreachedCorpus, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening breached password corpus: %w", err)
+	}
+	if info.IsDir() {
+		return &BreachedCorpus{dir: path}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening breached password corpus: %w", err)
+	}
+	defer f.Close()
+
+	hashes := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(hash) == sha1.Size*2 {
+			hashes[strings.ToUpper(hash)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading breached password corpus: %w", err)
+	}
+	return &BreachedCorpus{hashes: hashes}, nil
+}
+
+// Contains reports whether the password appears in the corpus.
+func (b *BreachedCorpus) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	if b.hashes != nil {
+		_, ok := b.hashes[hash]
+		return ok, nil
+	}
+	return b.containsInRange(hash[:5], hash[5:])
+}
+
+func (b *BreachedCorpus) containsInRange(prefix string, suffix string) (bool, error) {
+	f, err := os.Open(filepath.Join(b.dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = os.Open(filepath.Join(b.dir, prefix+".txt"))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,77 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+	"platform-service/internal/config"
+	"strings"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Hasher hashes passwords with one algorithm and verifies hashes made with
+// it.
+type Hasher interface {
+	// Hash returns the encoded hash of the password, including the algorithm
+	// parameters and salt.
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the hash.
+	Verify(hash string, password string) (bool, error)
+	// Recognizes reports whether the hash was made by this algorithm.
+	Recognizes(hash string) bool
+	// NeedsRehash reports whether a hash made by this algorithm uses other
+	// parameters than the hasher.
+	NeedsRehash(hash string) bool
+}
+
+var (
+	hasher  Hasher = NewBcryptHasher(10)
+	hashers        = []Hasher{hasher}
+)
+
+// initHasher selects the hasher for new passwords from
+// PASSWORD_HASH_ALGORITHM. Hashes of the other supported algorithms are still
+// verified, and upgraded on the next login.
+func initHasher() error {
+	cfg := config.GetPasswordHashing()
+	argon2id := NewArgon2idHasher(cfg.Argon2Memory, cfg.Argon2Time, cfg.Argon2Parallelism)
+	bcrypt := NewBcryptHasher(cfg.BcryptCost)
+
+	switch cfg.Algorithm {
+	case "argon2id":
+		hasher = argon2id
+	case "bcrypt":
+		hasher = bcrypt
+	default:
+		return fmt.Errorf("unknown PASSWORD_HASH_ALGORITHM: %q", cfg.Algorithm)
+	}
+	hashers = []Hasher{argon2id, bcrypt}
+	return nil
+}
+
+// Hash hashes a new password with the current algorithm and parameters.
+func Hash(password string) (string, error) {
+	return hasher.Hash(password)
+}
+
+// Verify checks the password against a hash of any supported algorithm. When
+// the password matches, needsRehash reports whether the hash should be
+// replaced by one made with Hash.
+func Verify(hash string, password string) (ok bool, needsRehash bool, err error) {
+	for _, h := range hashers {
+		if !h.Recognizes(hash) {
+			continue
+		}
+		ok, err := h.Verify(hash, password)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, h != hasher || h.NeedsRehash(hash), nil
+	}
+	return false, false, ErrUnknownHashFormat
+}
+
+// phcSegments splits a PHC string such as "$argon2id$v=19$m=65536,t=3,p=2$salt$hash".
+func phcSegments(hash string) []string {
+	return strings.Split(strings.TrimPrefix(hash, "$"), "$")
+}
@@ -0,0 +1,85 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// useHashers makes current the hasher of new passwords until the end of the
+// test, with legacy verified alongside it.
+func useHashers(t *testing.T, current Hasher, legacy ...Hasher) {
+	previous, previousHashers := hasher, hashers
+	hasher, hashers = current, append([]Hasher{current}, legacy...)
+	t.Cleanup(func() { hasher, hashers = previous, previousHashers })
+}
+
+func TestArgon2idHasherVerifiesReferenceHash(t *testing.T) {
+	// Produced by the reference implementation:
+	// echo -n password | argon2 somesalt -id -t 2 -m 16 -p 1 -e
+	const reference = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	h := NewArgon2idHasher(64*1024, 2, 1)
+
+	if ok, err := h.Verify(reference, "password"); err != nil || !ok {
+		t.Errorf("Verify = %v, %v, want true", ok, err)
+	}
+	if ok, err := h.Verify(reference, "Password"); err != nil || ok {
+		t.Errorf("Verify of a wrong password = %v, %v, want false", ok, err)
+	}
+	// Its salt is shorter than ours.
+	if !h.NeedsRehash(reference) {
+		t.Error("NeedsRehash = false for a hash with an eight byte salt")
+	}
+
+	hash, err := h.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.NeedsRehash(hash) {
+		t.Error("NeedsRehash = true for a hash made by the hasher")
+	}
+	if !NewArgon2idHasher(64*1024, 3, 1).NeedsRehash(hash) {
+		t.Error("NeedsRehash = false for a hash with fewer iterations")
+	}
+}
+
+func TestVerifyUpgradesBcryptHashes(t *testing.T) {
+	argon2id := NewArgon2idHasher(1024, 1, 1)
+	useHashers(t, argon2id, NewBcryptHasher(bcrypt.MinCost))
+
+	legacy, err := NewBcryptHasher(bcrypt.MinCost).Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, needsRehash, err := Verify(legacy, "correct horse battery staple")
+	if err != nil || !ok || !needsRehash {
+		t.Errorf("Verify of a bcrypt hash = %v, %v, %v, want a match that needs rehashing", ok, needsRehash, err)
+	}
+	if ok, needsRehash, err := Verify(legacy, "wrong password"); err != nil || ok || needsRehash {
+		t.Errorf("Verify of a wrong password = %v, %v, %v, want no match", ok, needsRehash, err)
+	}
+
+	upgraded, err := Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(upgraded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Hash = %q, want an Argon2id hash with the configured parameters", upgraded)
+	}
+	ok, needsRehash, err = Verify(upgraded, "correct horse battery staple")
+	if err != nil || !ok || needsRehash {
+		t.Errorf("Verify of the upgraded hash = %v, %v, %v, want a match that needs no rehashing", ok, needsRehash, err)
+	}
+}
+
+func TestVerifyRejectsUnknownHashFormats(t *testing.T) {
+	useHashers(t, NewArgon2idHasher(1024, 1, 1), NewBcryptHasher(bcrypt.MinCost))
+
+	for _, hash := range []string{"", "plaintext", "$argon2i$v=19$m=1024,t=1,p=1$c29tZXNhbHQ$aGFzaA", "$1$salt$hash"} {
+		if _, _, err := Verify(hash, "password"); !errors.Is(err, ErrUnknownHashFormat) {
+			t.Errorf("Verify(%q) = %v, want ErrUnknownHashFormat", hash, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package passwords
+
+import (
+	"fmt"
+	"platform-service/internal/config"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violation is a password policy rule that a password breaks.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password breaks.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	rules := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return "password violates policy: " + strings.Join(rules, ", ")
+}
+
+// Policy checks new passwords against the configured rules and, when a
+// corpus is configured, against known breached passwords.
+type Policy struct {
+	config.PasswordPolicy
+	breached *BreachedCorpus
+}
+
+var policy = &Policy{PasswordPolicy: config.PasswordPolicy{MinLength: 8, MaxBytes: 72}}
+
+// Init loads the password hasher and the policy from the environment,
+// including the breached password corpus.
+func Init() error {
+	if err := initHasher(); err != nil {
+		return err
+	}
+
+	p, err := NewPolicy(config.GetPasswordPolicy())
+	if err != nil {
+		return err
+	}
+	policy = p
+	return nil
+}
+
+func NewPolicy(cfg config.PasswordPolicy) (*Policy, error) {
+	p := &Policy{PasswordPolicy: cfg}
+	if cfg.BreachedCorpus != "" {
+		corpus, err := OpenBreachedCorpus(cfg.BreachedCorpus)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = corpus
+	}
+	return p, nil
+}
+
+// Check validates a new password for the user with the given username and
+// email with the configured policy. It returns a *PolicyError listing the
+// broken rules.
+func Check(password string, username string, email string) error {
+	return policy.Check(password, username, email)
+}
+
+func (p *Policy) Check(password string, username string, email string) error {
+	var violations []Violation
+	add := func(rule string, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		add("min_length", "Password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		add("max_length", "Password must not be longer than %d bytes", p.MaxBytes)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add("uppercase", "Password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		add("lowercase", "Password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add("digit", "Password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add("symbol", "Password must contain a symbol")
+	}
+
+	if p.DisallowUserInfo && containsUserInfo(password, username, email) {
+		add("user_info", "Password must not contain the username or email address")
+	}
+
+	if p.breached != nil && password != "" {
+		breached, err := p.breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			add("breached", "Password has appeared in a data breach; choose a different one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsUserInfo reports whether the password contains the username, the
+// email address or its local part, ignoring case. Parts shorter than three
+// characters are not checked.
+func containsUserInfo(password string, username string, email string) bool {
+	password = strings.ToLower(password)
+	parts := []string{username, email}
+	if local, _, ok := strings.Cut(email, "@"); ok {
+		parts = append(parts, local)
+	}
+	for _, part := range parts {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if len(part) >= 3 && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package passwords
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"platform-service/internal/config"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// violatedRules returns the rules the password breaks.
+func violatedRules(t *testing.T, p *Policy, password string) []string {
+	t.Helper()
+	err := p.Check(password, "alice", "alice.liddell@example.com")
+	if err == nil {
+		return nil
+	}
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Check = %v, want a *PolicyError", err)
+	}
+	var rules []string
+	for _, v := range policyErr.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p, err := NewPolicy(config.PasswordPolicy{
+		MinLength:        10,
+		MaxBytes:         72,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowUserInfo: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"Tr0ub4dor&3x", nil},
+		{"Sh0rt!", []string{"min_length"}},
+		{"alllowercase", []string{"uppercase", "digit", "symbol"}},
+		{"ALLUPPERCASE1!", []string{"lowercase"}},
+		{"Ünïcödé-Pässwört9", nil},
+		{"My-Alice-Password1", []string{"user_info"}},
+		{"Liddell.ALICE.99x", []string{"user_info"}},
+		{"Aa1!" + strings.Repeat("x", 69), []string{"max_length"}},
+	}
+	for _, tt := range tests {
+		if got := violatedRules(t, p, tt.password); !slices.Equal(got, tt.want) {
+			t.Errorf("Check(%q) broke %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyCountsCharactersNotBytes(t *testing.T) {
+	p, err := NewPolicy(config.PasswordPolicy{MinLength: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := violatedRules(t, p, "äöüäöüä"); !slices.Equal(got, []string{"min_length"}) {
+		t.Errorf("seven two-byte characters broke %v, want min_length", got)
+	}
+	if got := violatedRules(t, p, "äöüäöüäö"); got != nil {
+		t.Errorf("eight two-byte characters broke %v, want none", got)
+	}
+}
+
+func TestPolicyRejectsBreachedPasswords(t *testing.T) {
+	// The SHA-1 hash of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte("1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(file, []byte("5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8:9545824\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, corpus := range []string{dir, file} {
+		p, err := NewPolicy(config.PasswordPolicy{MinLength: 8, BreachedCorpus: corpus})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := violatedRules(t, p, "password"); !slices.Equal(got, []string{"breached"}) {
+			t.Errorf("%s: Check(\"password\") broke %v, want breached", corpus, got)
+		}
+		if got := violatedRules(t, p, "correct horse battery staple"); got != nil {
+			t.Errorf("%s: Check of an unbreached password broke %v, want none", corpus, got)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"encoding/json"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+)
+
+// RecordAudit writes an entry to the audit log. details is stored as JSON and
+// may be nil.
+func RecordAudit(action string, actorID string, subjectID string, ip string, details map[string]interface{}) error {
+	entry := &models.AuditLog{
+		Action:    action,
+		ActorID:   actorID,
+		SubjectID: subjectID,
+		IP:        ip,
+	}
+	if details != nil {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		entry.Details = string(encoded)
+	}
+	return database.DB.Create(entry).Error
+}
+
+// ListAuditLogs returns up to limit entries, newest first. An empty action or
+// userID does not filter; userID matches both the actor and the subject.
+func ListAuditLogs(action string, userID string, limit int) ([]models.AuditLog, error) {
+	query := database.DB.Order("id DESC").Limit(limit)
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if userID != "" {
+		query = query.Where("actor_id = ? OR subject_id = ?", userID, userID)
+	}
+
+	var entries []models.AuditLog
+	err := query.Find(&entries).Error
+	return entries, err
+}
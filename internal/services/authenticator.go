@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/passwords"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountInactive    = errors.New("account is not active")
+	ErrEmailNotVerified   = errors.New("email address is not verified")
+	ErrBackendUnavailable = errors.New("authentication backend is unavailable")
+)
+
+// Authenticator checks a password against one user store.
+type Authenticator interface {
+	// Authenticate returns the user the identifier and password belong to.
+	// user is the local user matching identifier, or nil if there is none.
+	// ErrInvalidCredentials lets the next authenticator try, and so does
+	// ErrBackendUnavailable when the store cannot be reached.
+	Authenticate(user *models.User, identifier string, password string, ip string) (*models.User, error)
+}
+
+var authenticators []Authenticator
+
+// InitAuthenticators sets up the backends listed in AUTH_BACKENDS.
+func InitAuthenticators() error {
+	authenticators = nil
+	for _, backend := range config.GetAuthBackends() {
+		switch backend {
+		case "database":
+			authenticators = append(authenticators, DatabaseAuthenticator{})
+		case "ldap":
+			authenticators = append(authenticators, NewLDAPAuthenticator(config.GetLDAPConfig()))
+		default:
+			return fmt.Errorf("unknown authentication backend %q", backend)
+		}
+	}
+	return nil
+}
+
+// Authenticate returns the active user matching the username or email
+// address and the password, trying each backend in turn. Failed attempts
+// count towards locking the account and the client IP, and while either is
+// locked it returns a *LockoutError without checking the password. When no
+// backend accepts the password and one of them could not be reached, it
+// returns ErrBackendUnavailable.
+func Authenticate(identifier string, password string, ip string) (*models.User, error) {
+	storedUser, err := FindUserByIdentifier(identifier)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Logins by username and by email address count against the same lock.
+	username := identifier
+	if storedUser != nil {
+		username = storedUser.Username
+	}
+	if err := CheckLoginThrottle(username, ip); err != nil {
+		return nil, err
+	}
+
+	var unavailable error
+	for _, authenticator := range authenticators {
+		user, err := authenticator.Authenticate(storedUser, identifier, password, ip)
+		if errors.Is(err, ErrInvalidCredentials) {
+			continue
+		}
+		if errors.Is(err, ErrBackendUnavailable) {
+			log.Printf("Error authenticating user: %v", err)
+			unavailable = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := RecordLoginSuccess(username); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	if err := RecordLoginFailure(username, ip); err != nil {
+		return nil, err
+	}
+	if unavailable != nil {
+		return nil, unavailable
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// DatabaseAuthenticator checks passwords against the hashes stored with
+// users. Users managed by the directory are left to the LDAP backend.
+type DatabaseAuthenticator struct{}
+
+func (DatabaseAuthenticator) Authenticate(user *models.User, identifier string, password string, ip string) (*models.User, error) {
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+	if managed, err := isDirectoryManaged(user); err != nil {
+		return nil, err
+	} else if managed {
+		return nil, ErrInvalidCredentials
+	}
+	if user.IsPendingVerification() {
+		return nil, ErrEmailNotVerified
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+
+	ok, needsRehash, err := passwords.Verify(user.Password, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	if needsRehash {
+		rehashPassword(user, password)
+	}
+	return user, nil
+}
+
+// rehashPassword replaces a hash made with an outdated algorithm or
+// parameters. Failures are only logged, the login itself already succeeded.
+func rehashPassword(user *models.User, password string) {
+	hashedPassword, err := passwords.Hash(password)
+	if err == nil {
+		err = database.DB.Model(user).Update("password", hashedPassword).Error
+	}
+	if err != nil {
+		log.Printf("Error rehashing password of user %s: %v", user.UID, err)
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/passwords"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// useDatabaseAuthentication checks passwords against the database, hashing
+// new ones with a cheap Argon2id, until the end of the test.
+func useDatabaseAuthentication(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		// Restore the package default of bcrypt, which keeps other tests fast.
+		viper.Set("PASSWORD_HASH_ALGORITHM", "bcrypt")
+		if err := passwords.Init(); err != nil {
+			t.Error(err)
+		}
+		viper.Set("PASSWORD_HASH_ALGORITHM", "")
+		authenticators = nil
+	})
+	setConfig(t, map[string]string{
+		"AUTH_BACKENDS":           "database",
+		"PASSWORD_HASH_ALGORITHM": "argon2id",
+		"ARGON2_MEMORY":           "1024",
+		"ARGON2_TIME":             "1",
+		"ARGON2_PARALLELISM":      "1",
+	})
+	if err := passwords.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitAuthenticators(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginUpgradesBcryptHash(t *testing.T) {
+	setupTestDB(t)
+	useDatabaseAuthentication(t)
+	user := registerTestUser(t, "alice")
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse battery staple"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Model(user).Update("password", string(legacy)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Authenticate("alice", "wrong password", "127.0.0.1"); err == nil {
+		t.Fatal("Authenticate with a wrong password succeeded")
+	}
+	stored := new(models.User)
+	if err := database.DB.First(stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password != string(legacy) {
+		t.Error("a failed login replaced the hash")
+	}
+
+	if _, err := Authenticate("alice", "correct horse battery staple", "127.0.0.1"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if err := database.DB.First(stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored.Password, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("stored hash = %q, want it upgraded to Argon2id", stored.Password)
+	}
+	if _, err := Authenticate("alice", "correct horse battery staple", "127.0.0.1"); err != nil {
+		t.Errorf("Authenticate with the upgraded hash: %v", err)
+	}
+}
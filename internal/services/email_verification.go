@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/mail"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrVerificationTokenInvalid = errors.New("verification token is invalid or expired")
+
+// SendVerificationEmail emails the user a link that verifies their current
+// address.
+func SendVerificationEmail(user *models.User) error {
+	expiresAt := time.Now().Add(config.GetEmailVerificationTTL())
+	token, err := utils.GenerateActionToken(models.TokenUseEmailVerification, user.UID, user.Email, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(config.GetEmailVerificationURL(), token)
+	if err != nil {
+		return err
+	}
+
+	return mail.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s\n\nIf you did not create an account, you can ignore this email.\n",
+			user.Username, config.GetEmailVerificationTTL(), link),
+	})
+}
+
+// linkWithToken adds the token query parameter to a link sent by email.
+func linkWithToken(base string, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// ResendVerificationEmail sends a new link if the address belongs to an
+// unverified user. Other addresses are ignored without an error, so callers
+// cannot learn which addresses are registered.
+func ResendVerificationEmail(email string) error {
+	user := new(models.User)
+	err := database.DB.Where("normalized_email = ?", models.NormalizeIdentifier(email)).First(user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	return SendVerificationEmail(user)
+}
+
+// VerifyEmail marks the address the token was issued for as verified and
+// activates the account if it was waiting for verification. Tokens for an
+// address the user has since changed are rejected.
+func VerifyEmail(token string) (*models.User, error) {
+	claims, err := utils.ParseActionToken(token, models.TokenUseEmailVerification)
+	if err != nil {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	user := new(models.User)
+	err = database.DB.Where("uid = ?", claims.Subject).First(user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrVerificationTokenInvalid
+	} else if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, claims.Email) {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	updates := map[string]interface{}{"email_verified": true}
+	if user.IsPendingVerification() {
+		updates["status"] = models.UserStatusActive
+	}
+	if err := database.DB.Model(user).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	user.EmailVerified = true
+	if user.IsPendingVerification() {
+		user.SetStatus(models.UserStatusActive)
+	}
+	return user, nil
+}
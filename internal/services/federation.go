@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const FederatedLoginStateTTL = 10 * time.Minute
+
+var (
+	ErrFederationProviderNotFound = errors.New("identity provider not found")
+	ErrFederationStateInvalid     = errors.New("federated login state is invalid or expired")
+	ErrFederationFailed           = errors.New("identity provider login failed")
+	ErrFederationEmailUnverified  = errors.New("identity provider did not return a verified email address")
+	ErrFederationLinkUnverified   = errors.New("existing account has an unverified email address")
+)
+
+// federatedProvider is a configured provider whose discovery document has
+// been loaded.
+type federatedProvider struct {
+	config.FederationProvider
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// federatedClaims are the ID token claims used to link and provision users.
+type federatedClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     *bool  `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	Picture           string `json:"picture"`
+}
+
+var (
+	federationMu       sync.Mutex
+	federatedProviders = make(map[string]*federatedProvider)
+	federationClient   = &http.Client{Timeout: 10 * time.Second}
+)
+
+// ListFederationProviders returns the configured identity providers.
+func ListFederationProviders() []config.FederationProvider {
+	return config.GetFederationProviders()
+}
+
+// loadFederatedProvider returns the named provider, fetching its discovery
+// document on first use so that an unreachable provider does not keep the
+// service from starting.
+func loadFederatedProvider(name string) (*federatedProvider, error) {
+	federationMu.Lock()
+	defer federationMu.Unlock()
+
+	if provider, ok := federatedProviders[name]; ok {
+		return provider, nil
+	}
+
+	for _, cfg := range config.GetFederationProviders() {
+		if cfg.Name != name {
+			continue
+		}
+
+		// The context outlives this call: the provider's key set uses it to
+		// refresh the signing keys.
+		ctx := oidc.ClientContext(context.Background(), federationClient)
+		discovered, err := oidc.NewProvider(ctx, cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover %s: %w", cfg.Issuer, err)
+		}
+
+		provider := &federatedProvider{
+			FederationProvider: cfg,
+			oauth2: &oauth2.Config{
+				ClientID:     cfg.ClientID,
+				ClientSecret: cfg.ClientSecret,
+				Endpoint:     discovered.Endpoint(),
+				RedirectURL:  config.GetFederationCallbackURL(cfg.Name),
+				Scopes:       cfg.Scopes,
+			},
+			verifier: discovered.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		}
+		federatedProviders[name] = provider
+		return provider, nil
+	}
+	return nil, ErrFederationProviderNotFound
+}
+
+// BeginFederatedLogin returns the provider URL to redirect the browser to and
+// the state the callback must present.
+func BeginFederatedLogin(name string) (string, string, error) {
+	provider, err := loadFederatedProvider(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = database.DB.Create(&models.FederatedLoginState{
+		StateHash:    utils.HashToken(state),
+		Provider:     name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(FederatedLoginStateTTL),
+	}).Error
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL := provider.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	return authURL, state, nil
+}
+
+// FinishFederatedLogin exchanges the authorization code, verifies the ID
+// token and returns the user it belongs to. Users are found by a previously
+// linked identity, then by verified email address, and are otherwise
+// registered.
+func FinishFederatedLogin(ctx context.Context, name string, state string, code string, ip string) (*models.User, error) {
+	provider, err := loadFederatedProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	loginState, err := consumeFederatedLoginState(name, state)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, federationClient)
+	token, err := provider.oauth2.Exchange(ctx, code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFederationFailed, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: no id_token in token response", ErrFederationFailed)
+	}
+	idToken, err := provider.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFederationFailed, err)
+	}
+	if idToken.Nonce != loginState.Nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrFederationFailed)
+	}
+
+	var claims federatedClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFederationFailed, err)
+	}
+	emailVerified := provider.TrustEmail || (claims.EmailVerified != nil && *claims.EmailVerified)
+	return resolveFederatedUser(name, idToken.Subject, claims, emailVerified, ip)
+}
+
+func consumeFederatedLoginState(name string, state string) (*models.FederatedLoginState, error) {
+	loginState := new(models.FederatedLoginState)
+	err := database.DB.Where("state_hash = ? AND provider = ?", utils.HashToken(state), name).First(loginState).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFederationStateInvalid
+	} else if err != nil {
+		return nil, err
+	}
+	if time.Now().After(loginState.ExpiresAt) {
+		return nil, ErrFederationStateInvalid
+	}
+
+	update := database.DB.Model(&models.FederatedLoginState{}).
+		Where("id = ? AND used_at IS NULL", loginState.ID).
+		Update("used_at", time.Now())
+	if update.Error != nil {
+		return nil, update.Error
+	}
+	if update.RowsAffected == 0 {
+		return nil, ErrFederationStateInvalid
+	}
+	return loginState, nil
+}
+
+// resolveFederatedUser returns the user linked to the provider's subject. An
+// unlinked subject is linked to the account with the same email address,
+// provided both sides verified it, so that nobody can take over an account
+// by registering someone else's address. Without such an account a new user
+// is registered.
+func resolveFederatedUser(provider string, subject string, claims federatedClaims, emailVerified bool, ip string) (*models.User, error) {
+	identity := new(models.FederatedIdentity)
+	err := database.DB.Where("provider = ? AND subject = ?", provider, subject).First(identity).Error
+	if err == nil {
+		user := new(models.User)
+		if err := database.DB.First(user, identity.UserID).Error; err != nil {
+			return nil, err
+		}
+		err := database.DB.Model(identity).Updates(map[string]interface{}{
+			"email":         claims.Email,
+			"last_login_at": time.Now(),
+		}).Error
+		return user, err
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !emailVerified {
+		return nil, ErrFederationEmailUnverified
+	}
+
+	user := new(models.User)
+	err = database.DB.Where("normalized_email = ?", models.NormalizeIdentifier(claims.Email)).First(user).Error
+	switch {
+	case err == nil:
+		if !user.EmailVerified {
+			return nil, ErrFederationLinkUnverified
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		username, err := availableUsername(claims.PreferredUsername, claims.Email)
+		if err != nil {
+			return nil, err
+		}
+		user, err = RegisterUser(NewUser{
+			Username:      username,
+			Email:         claims.Email,
+			FirstName:     claims.GivenName,
+			LastName:      claims.FamilyName,
+			ProfileImage:  claims.Picture,
+			EmailVerified: true,
+			IP:            ip,
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	err = database.DB.Create(&models.FederatedIdentity{
+		UserID:      user.ID,
+		Provider:    provider,
+		Subject:     subject,
+		Email:       claims.Email,
+		LastLoginAt: time.Now(),
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+var usernameDisallowed = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// updateFederatedProfile keeps the user's name in sync with the identity
+// provider.
+func updateFederatedProfile(user *models.User, claims federatedClaims) error {
+	updates := make(map[string]interface{})
+	if claims.GivenName != "" && claims.GivenName != user.FirstName {
+		updates["first_name"] = claims.GivenName
+		user.FirstName = claims.GivenName
+	}
+	if claims.FamilyName != "" && claims.FamilyName != user.LastName {
+		updates["last_name"] = claims.FamilyName
+		user.LastName = claims.FamilyName
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return database.DB.Model(user).Updates(updates).Error
+}
+
+// availableUsername derives a free username for a provisioned user from the
+// preferred username or the local part of the email address.
+func availableUsername(preferred string, email string) (string, error) {
+	base := preferred
+	if base == "" || strings.Contains(base, "@") {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = usernameDisallowed.ReplaceAllString(strings.ToLower(base), "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if len(base) < 3 {
+		base = "user" + base
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 5; attempt++ {
+		var count int64
+		err := database.DB.Unscoped().Model(&models.User{}).
+			Where("normalized_username = ? OR normalized_email = ?", candidate, candidate).
+			Count(&count).Error
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+
+		suffix, err := utils.GenerateOpaqueToken(3)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "-" + strings.ToLower(usernameDisallowed.ReplaceAllString(suffix, ""))
+	}
+	return "", ErrUserExists
+}
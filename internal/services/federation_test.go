@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeOIDCIssuer is an OpenID Connect provider serving discovery, JWKS and
+// the token endpoint. Tests authorize a login with authorize, standing in for
+// the user signing in at the provider.
+type fakeOIDCIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]fakeOIDCGrant
+}
+
+type fakeOIDCGrant struct {
+	challenge string
+	idToken   jwt.MapClaims
+	key       *rsa.PrivateKey
+}
+
+const fakeOIDCClientID = "platform"
+
+func newFakeOIDCIssuer(t *testing.T) *fakeOIDCIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &fakeOIDCIssuer{t: t, key: key, codes: make(map[string]fakeOIDCGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                issuer.server.URL,
+			"authorization_endpoint":                issuer.server.URL + "/authorize",
+			"token_endpoint":                        issuer.server.URL + "/token",
+			"jwks_uri":                              issuer.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// authorize signs the user in at the provider for the authorization URL and
+// returns the code the provider redirects back with. The ID token carries
+// the claims, the URL's nonce and the standard claims.
+func (i *fakeOIDCIssuer) authorize(authURL string, claims jwt.MapClaims) string {
+	i.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != fakeOIDCClientID || query.Get("code_challenge_method") != "S256" {
+		i.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	now := time.Now()
+	idToken := jwt.MapClaims{
+		"iss":   i.server.URL,
+		"aud":   fakeOIDCClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idToken[name] = value
+	}
+
+	codeBytes := make([]byte, 16)
+	rand.Read(codeBytes)
+	code := hex.EncodeToString(codeBytes)
+	i.mu.Lock()
+	i.codes[code] = fakeOIDCGrant{challenge: query.Get("code_challenge"), idToken: idToken, key: i.key}
+	i.mu.Unlock()
+	return code
+}
+
+// signWith makes the ID token issued for code be signed by key.
+func (i *fakeOIDCIssuer) signWith(code string, key *rsa.PrivateKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	grant := i.codes[code]
+	grant.key = key
+	i.codes[code] = grant
+}
+
+func (i *fakeOIDCIssuer) token(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	grant, ok := i.codes[r.PostFormValue("code")]
+	delete(i.codes, r.PostFormValue("code"))
+	i.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.idToken)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(grant.key)
+	if err != nil {
+		i.t.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+func setupTestFederation(t *testing.T) *fakeOIDCIssuer {
+	t.Helper()
+	setupTestDB(t)
+	issuer := newFakeOIDCIssuer(t)
+	setConfig(t, map[string]string{
+		"FEDERATION_PROVIDERS":          "test",
+		"FEDERATION_TEST_ISSUER":        issuer.server.URL,
+		"FEDERATION_TEST_CLIENT_ID":     fakeOIDCClientID,
+		"FEDERATION_TEST_CLIENT_SECRET": "secret",
+	})
+	t.Cleanup(func() {
+		federationMu.Lock()
+		delete(federatedProviders, "test")
+		federationMu.Unlock()
+	})
+	return issuer
+}
+
+func TestFederatedLoginRegistersAndLinksUser(t *testing.T) {
+	issuer := setupTestFederation(t)
+	claims := jwt.MapClaims{
+		"sub":            "subject-1",
+		"email":          "carol@example.com",
+		"email_verified": true,
+		"given_name":     "Carol",
+	}
+
+	var uid string
+	for i := 0; i < 2; i++ {
+		authURL, state, err := BeginFederatedLogin("test")
+		if err != nil {
+			t.Fatalf("BeginFederatedLogin: %v", err)
+		}
+		code := issuer.authorize(authURL, claims)
+		user, err := FinishFederatedLogin(context.Background(), "test", state, code, "127.0.0.1")
+		if err != nil {
+			t.Fatalf("FinishFederatedLogin: %v", err)
+		}
+		if user.Email != "carol@example.com" || user.FirstName != "Carol" || !user.EmailVerified {
+			t.Errorf("user = %q %q verified=%v, want the provider's profile", user.Email, user.FirstName, user.EmailVerified)
+		}
+		if uid != "" && user.UID != uid {
+			t.Errorf("second login returned user %s, want the linked user %s", user.UID, uid)
+		}
+		uid = user.UID
+	}
+}
+
+func TestFederatedLoginRejectsTokenSignedByOtherKey(t *testing.T) {
+	issuer := setupTestFederation(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, state, err := BeginFederatedLogin("test")
+	if err != nil {
+		t.Fatalf("BeginFederatedLogin: %v", err)
+	}
+	code := issuer.authorize(authURL, jwt.MapClaims{"sub": "subject-1", "email": "carol@example.com", "email_verified": true})
+	issuer.signWith(code, otherKey)
+
+	if _, err := FinishFederatedLogin(context.Background(), "test", state, code, "127.0.0.1"); !errors.Is(err, ErrFederationFailed) {
+		t.Errorf("FinishFederatedLogin = %v, want ErrFederationFailed", err)
+	}
+}
+
+func TestFederatedLoginRejectsUnverifiedEmail(t *testing.T) {
+	issuer := setupTestFederation(t)
+
+	authURL, state, err := BeginFederatedLogin("test")
+	if err != nil {
+		t.Fatalf("BeginFederatedLogin: %v", err)
+	}
+	code := issuer.authorize(authURL, jwt.MapClaims{"sub": "subject-1", "email": "carol@example.com", "email_verified": false})
+
+	if _, err := FinishFederatedLogin(context.Background(), "test", state, code, "127.0.0.1"); !errors.Is(err, ErrFederationEmailUnverified) {
+		t.Errorf("FinishFederatedLogin = %v, want ErrFederationEmailUnverified", err)
+	}
+}
+
+func TestFederatedLoginRejectsReusedState(t *testing.T) {
+	issuer := setupTestFederation(t)
+	claims := jwt.MapClaims{"sub": "subject-1", "email": "carol@example.com", "email_verified": true}
+
+	authURL, state, err := BeginFederatedLogin("test")
+	if err != nil {
+		t.Fatalf("BeginFederatedLogin: %v", err)
+	}
+	if _, err := FinishFederatedLogin(context.Background(), "test", state, issuer.authorize(authURL, claims), "127.0.0.1"); err != nil {
+		t.Fatalf("FinishFederatedLogin: %v", err)
+	}
+	if _, err := FinishFederatedLogin(context.Background(), "test", state, issuer.authorize(authURL, claims), "127.0.0.1"); !errors.Is(err, ErrFederationStateInvalid) {
+		t.Errorf("FinishFederatedLogin with a used state = %v, want ErrFederationStateInvalid", err)
+	}
+}
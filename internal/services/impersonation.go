@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/config"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"time"
+)
+
+var (
+	ErrImpersonationForbidden = errors.New("user cannot be impersonated")
+	ErrNotImpersonating       = errors.New("token is not an impersonation token")
+)
+
+// StartImpersonation issues a short-lived access token for the target user
+// that names the admin in its act claim. No refresh token or session is
+// created, so the impersonation ends when the token expires at the latest.
+// Admins, that is users holding any permission checked by this service, and
+// inactive users cannot be impersonated.
+func StartImpersonation(admin *models.User, target *models.User, reason string, ip string) (string, time.Time, error) {
+	if err := ensureUserRoles(target); err != nil {
+		return "", time.Time{}, err
+	}
+	if target.ID == admin.ID || target.IsAdmin() || !target.IsActive() {
+		return "", time.Time{}, ErrImpersonationForbidden
+	}
+
+	expiresAt := time.Now().Add(config.GetImpersonationTTL())
+	var tokenID string
+	token, err := utils.GenerateJWT(target.UID, target.Username, expiresAt,
+		utils.WithRoles(target),
+		utils.WithActor(admin.UID, admin.Username),
+		func(claims *models.JwtCustomClaims) { tokenID = claims.ID },
+	)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	err = RecordAudit(models.AuditImpersonationStart, admin.UID, target.UID, ip, map[string]interface{}{
+		"token_id":   tokenID,
+		"reason":     reason,
+		"expires_at": expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// EndImpersonation revokes the impersonation token described by claims.
+func EndImpersonation(claims *models.JwtCustomClaims, ip string) error {
+	if !claims.IsImpersonation() {
+		return ErrNotImpersonating
+	}
+	if err := Revocations.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+	return RecordAudit(models.AuditImpersonationEnd, claims.Actor.Subject, claims.UserID, ip, map[string]interface{}{
+		"token_id": claims.ID,
+	})
+}
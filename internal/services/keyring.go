@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InitKeyring loads the persisted keyring, seeding it with the configured key
+// on first start, and keeps it in sync with keys rotated by other replicas.
+func InitKeyring() error {
+	if err := LoadKeyring(); err != nil {
+		return err
+	}
+	utils.Keys.Refresh = LoadKeyring
+
+	go func() {
+		ticker := time.NewTicker(config.GetKeyringSyncInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := LoadKeyring(); err != nil {
+				log.Printf("Error loading signing keys: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// LoadKeyring replaces utils.Keys with the unexpired persisted keys.
+func LoadKeyring() error {
+	if err := seedKeyring(); err != nil {
+		return err
+	}
+
+	var records []models.SigningKey
+	err := database.DB.
+		Where("status = ? OR expires_at > ?", models.SigningKeyStatusCurrent, time.Now()).
+		Order("created_at DESC").
+		Find(&records).Error
+	if err != nil {
+		return err
+	}
+
+	var current *utils.SigningKey
+	var retired []*utils.SigningKey
+	for _, record := range records {
+		key, err := decodeSigningKey(&record)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key %s: %w", record.KID, err)
+		}
+		// Should two rotations race, the newest current key wins and the
+		// other one keeps verifying tokens it already signed.
+		if current == nil && record.IsCurrent() {
+			current = key
+		} else {
+			retired = append(retired, key)
+		}
+	}
+	if current == nil {
+		return errors.New("no current signing key")
+	}
+
+	utils.Keys.Set(current, retired)
+	return nil
+}
+
+// seedKeyring persists the configured key as the current key when there is
+// none yet. Replicas starting together derive the same key ID from the same
+// configured key, so all but the first insert are ignored.
+func seedKeyring() error {
+	var count int64
+	if err := database.DB.Model(&models.SigningKey{}).
+		Where("status = ?", models.SigningKeyStatusCurrent).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	key, err := utils.LoadConfiguredSigningKey()
+	if err != nil {
+		return err
+	}
+	record, err := newSigningKeyRecord(key)
+	if err != nil {
+		return err
+	}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "k_id"}},
+		DoNothing: true,
+	}).Create(record).Error
+}
+
+// RotateSigningKey makes a freshly generated key of the configured algorithm
+// current. The previous key keeps verifying tokens for JWT_KEY_RETENTION and
+// only the newest JWT_MAX_RETIRED_KEYS retired keys are kept.
+func RotateSigningKey() (*models.SigningKey, error) {
+	key, err := utils.GenerateSigningKey(config.GetJWTSigningAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+	record, err := newSigningKeyRecord(key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(config.GetJWTKeyRetention())
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.SigningKey{}).
+			Where("status = ?", models.SigningKeyStatusCurrent).
+			Updates(map[string]interface{}{
+				"status":     models.SigningKeyStatusRetired,
+				"retired_at": now,
+				"expires_at": expiresAt,
+			}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		return pruneSigningKeys(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := LoadKeyring(); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ListSigningKeys returns the persisted keys, newest first.
+func ListSigningKeys() ([]models.SigningKey, error) {
+	var records []models.SigningKey
+	err := database.DB.Order("created_at DESC").Find(&records).Error
+	return records, err
+}
+
+func pruneSigningKeys(tx *gorm.DB) error {
+	err := tx.Unscoped().
+		Where("status = ? AND expires_at <= ?", models.SigningKeyStatusRetired, time.Now()).
+		Delete(&models.SigningKey{}).Error
+	if err != nil {
+		return err
+	}
+
+	var retired []models.SigningKey
+	err = tx.Where("status = ?", models.SigningKeyStatusRetired).
+		Order("retired_at DESC").
+		Find(&retired).Error
+	if err != nil {
+		return err
+	}
+
+	limit := config.GetJWTMaxRetiredKeys()
+	if len(retired) <= limit {
+		return nil
+	}
+	var ids []uint
+	for _, record := range retired[limit:] {
+		ids = append(ids, record.ID)
+	}
+	return tx.Unscoped().Delete(&models.SigningKey{}, ids).Error
+}
+
+func newSigningKeyRecord(key *utils.SigningKey) (*models.SigningKey, error) {
+	material, err := utils.EncodeSigningKey(key)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.Encrypt(material)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SigningKey{
+		KID:       key.ID,
+		Algorithm: key.Method.Alg(),
+		Material:  encrypted,
+		Status:    models.SigningKeyStatusCurrent,
+	}, nil
+}
+
+func decodeSigningKey(record *models.SigningKey) (*utils.SigningKey, error) {
+	material, err := utils.Decrypt(record.Material)
+	if err != nil {
+		return nil, err
+	}
+	key, err := utils.DecodeSigningKey(record.Algorithm, material)
+	if err != nil {
+		return nil, err
+	}
+	if record.ExpiresAt != nil && !record.IsCurrent() {
+		key.NotAfter = *record.ExpiresAt
+	}
+	return key, nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestSeedKeyringToleratesConcurrentSeed(t *testing.T) {
+	setupTestDB(t)
+
+	// Another replica seeds the same key between this replica's check for a
+	// current key and its insert.
+	seeded := false
+	err := database.DB.Callback().Create().Before("gorm:create").Register("test:concurrent_seed", func(tx *gorm.DB) {
+		record, ok := tx.Statement.Dest.(*models.SigningKey)
+		if !ok || seeded {
+			return
+		}
+		seeded = true
+		now := time.Now()
+		err := database.DB.Exec(
+			"INSERT INTO signing_keys (k_id, algorithm, material, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+			record.KID, record.Algorithm, record.Material, record.Status, now, now,
+		).Error
+		if err != nil {
+			t.Errorf("seeding as another replica: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := seedKeyring(); err != nil {
+		t.Fatalf("seedKeyring: %v", err)
+	}
+	if !seeded {
+		t.Fatal("the other replica did not seed the key")
+	}
+	var count int64
+	if err := database.DB.Model(&models.SigningKey{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("seeded %d keys, want 1", count)
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"slices"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ldapProvider is the FederatedIdentity provider name of LDAP users.
+const ldapProvider = "ldap"
+
+var (
+	ErrLDAPEmailMissing = errors.New("LDAP entry has no email address")
+	ErrDirectoryManaged = errors.New("password is managed by the directory")
+)
+
+// LDAPAuthenticator checks passwords by binding to a directory such as
+// Active Directory. Directory users are linked to a local shadow user like
+// federated logins, which is kept in sync with their entry on every login.
+type LDAPAuthenticator struct {
+	config config.LDAPConfig
+}
+
+func NewLDAPAuthenticator(cfg config.LDAPConfig) *LDAPAuthenticator {
+	return &LDAPAuthenticator{config: cfg}
+}
+
+func (a *LDAPAuthenticator) Authenticate(_ *models.User, identifier string, password string, ip string) (*models.User, error) {
+	// Directories accept a bind without a password for any DN as an
+	// unauthenticated bind.
+	if identifier == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	entry, err := a.bind(identifier, password)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := federatedClaims{
+		PreferredUsername: entry.GetEqualFoldAttributeValue(a.config.UsernameAttribute),
+		Email:             entry.GetEqualFoldAttributeValue(a.config.EmailAttribute),
+		GivenName:         entry.GetEqualFoldAttributeValue(a.config.FirstNameAttribute),
+		FamilyName:        entry.GetEqualFoldAttributeValue(a.config.LastNameAttribute),
+	}
+	if claims.Email == "" {
+		return nil, ErrLDAPEmailMissing
+	}
+
+	// The directory is trusted to hold its users' real addresses.
+	user, err := resolveFederatedUser(ldapProvider, strings.ToLower(entry.DN), claims, true, ip)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+	if err := updateFederatedProfile(user, claims); err != nil {
+		return nil, err
+	}
+	if err := syncManagedRoles(user, a.managedRoles(), a.roles(entry.GetEqualFoldAttributeValues(a.config.GroupsAttribute)), ip); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// isDirectoryManaged reports whether the user is linked to a directory entry
+// while the LDAP backend is enabled. The directory owns the passwords of
+// those users, so local passwords, password resets and magic links are not
+// accepted for them; otherwise disabling the entry would not lock them out.
+func isDirectoryManaged(user *models.User) (bool, error) {
+	if !slices.Contains(config.GetAuthBackends(), "ldap") {
+		return false, nil
+	}
+	var count int64
+	err := database.DB.Model(&models.FederatedIdentity{}).
+		Where("user_id = ? AND provider = ?", user.ID, ldapProvider).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// bind binds as the user and returns their entry. It returns
+// ErrInvalidCredentials if the directory rejects the password or the user is
+// not found below the search base.
+func (a *LDAPAuthenticator) bind(identifier string, password string) (*ldap.Entry, error) {
+	conn, err := ldap.DialURL(a.config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: a.config.Timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to connect to LDAP: %v", ErrBackendUnavailable, err)
+	}
+	defer conn.Close()
+	conn.SetTimeout(a.config.Timeout)
+
+	if a.config.StartTLS {
+		u, err := url.Parse(a.config.URL)
+		if err != nil {
+			return nil, err
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			return nil, ldapError("failed to start TLS with LDAP", err)
+		}
+	}
+
+	bindDN := strings.ReplaceAll(a.config.BindDNTemplate, "%s", ldap.EscapeDN(identifier))
+	if err := conn.Bind(bindDN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, ldapError("failed to bind to LDAP", err)
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.config.SearchBase, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(a.config.Timeout.Seconds()), false,
+		strings.ReplaceAll(a.config.SearchFilter, "%s", ldap.EscapeFilter(identifier)),
+		[]string{
+			a.config.UsernameAttribute, a.config.EmailAttribute, a.config.FirstNameAttribute,
+			a.config.LastNameAttribute, a.config.GroupsAttribute,
+		},
+		nil,
+	))
+	if err != nil {
+		return nil, ldapError("failed to search LDAP", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+// ldapError wraps err, marking errors of an unreachable or overloaded
+// directory with ErrBackendUnavailable.
+func ldapError(message string, err error) error {
+	if ldap.IsErrorAnyOf(err, ldap.ErrorNetwork, ldap.LDAPResultBusy, ldap.LDAPResultUnavailable, ldap.LDAPResultTimeLimitExceeded) {
+		return fmt.Errorf("%w: %s: %v", ErrBackendUnavailable, message, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// managedRoles returns the roles set from the user's groups on each login,
+// which are those named by the group mappings.
+func (a *LDAPAuthenticator) managedRoles() []string {
+	var roles []string
+	for _, mapping := range a.config.GroupRoles {
+		roles = append(roles, mapping.Role)
+	}
+	slices.Sort(roles)
+	return slices.Compact(roles)
+}
+
+// roles returns the roles of every group mapping the user is a member of.
+func (a *LDAPAuthenticator) roles(groups []string) []string {
+	var roles []string
+	for _, mapping := range a.config.GroupRoles {
+		for _, group := range groups {
+			if sameDN(group, mapping.Group) {
+				roles = append(roles, mapping.Role)
+				break
+			}
+		}
+	}
+	return roles
+}
+
+// sameDN compares DNs ignoring case and spacing between their components.
+func sameDN(a string, b string) bool {
+	dnA, errA := ldap.ParseDN(a)
+	dnB, errB := ldap.ParseDN(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a, b)
+	}
+	return dnA.EqualFold(dnB)
+}
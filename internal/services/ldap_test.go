@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/passwords"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jimlambrt/gldap"
+)
+
+const testLDAPPeople = "ou=people,dc=example,dc=com"
+
+type testLDAPUser struct {
+	password   string
+	attributes map[string][]string
+}
+
+var testLDAPFilter = regexp.MustCompile(`^\(uid=([^)]*)\)$`)
+
+// startTestLDAPServer serves the users below testLDAPPeople, keyed by uid,
+// and returns the server's URL. Searches are only answered after a
+// successful bind, like directories that disallow anonymous reads.
+func startTestLDAPServer(t *testing.T, users map[string]testLDAPUser) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	var mu sync.Mutex
+	bound := make(map[int]bool)
+	mux, err := gldap.NewMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+		defer w.Write(resp)
+		m, err := r.GetSimpleBindMessage()
+		if err != nil {
+			return
+		}
+		uid := strings.TrimSuffix(strings.TrimPrefix(m.UserName, "uid="), ","+testLDAPPeople)
+		if user, ok := users[uid]; ok && string(m.Password) == user.password {
+			mu.Lock()
+			bound[r.ConnectionID()] = true
+			mu.Unlock()
+			resp.SetResultCode(gldap.ResultSuccess)
+		}
+	})
+	mux.Search(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+		defer w.Write(resp)
+		mu.Lock()
+		ok := bound[r.ConnectionID()]
+		mu.Unlock()
+		if !ok {
+			resp.SetResultCode(gldap.ResultInsufficientAccessRights)
+			return
+		}
+		m, err := r.GetSearchMessage()
+		if err != nil {
+			return
+		}
+		match := testLDAPFilter.FindStringSubmatch(m.Filter)
+		if match == nil {
+			return
+		}
+		if user, ok := users[match[1]]; ok {
+			w.Write(r.NewSearchResponseEntry("uid="+match[1]+","+testLDAPPeople, gldap.WithAttributes(user.attributes)))
+		}
+	})
+
+	server, err := gldap.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Router(mux); err != nil {
+		t.Fatal(err)
+	}
+	go server.Run(addr)
+	t.Cleanup(func() { server.Stop() })
+	for deadline := time.Now().Add(5 * time.Second); !server.Ready(); {
+		if time.Now().After(deadline) {
+			t.Fatal("LDAP server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Sprintf("ldap://%s", addr)
+}
+
+func setupTestLDAP(t *testing.T) {
+	t.Helper()
+	setupTestDB(t)
+	url := startTestLDAPServer(t, map[string]testLDAPUser{
+		"alice": {
+			password: "directory-password",
+			attributes: map[string][]string{
+				"uid":       {"alice"},
+				"mail":      {"alice@example.com"},
+				"givenName": {"Alice"},
+				"sn":        {"Liddell"},
+				"memberOf":  {"CN=Admins,OU=Groups,DC=example,DC=com"},
+			},
+		},
+	})
+	setConfig(t, map[string]string{
+		"AUTH_BACKENDS":         "ldap,database",
+		"LDAP_URL":              url,
+		"LDAP_BIND_DN_TEMPLATE": "uid=%s," + testLDAPPeople,
+		"LDAP_SEARCH_BASE":      "dc=example,dc=com",
+		"LDAP_GROUP_ROLES":      "cn=admins,ou=groups,dc=example,dc=com=admin",
+	})
+	if err := InitAuthenticators(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { authenticators = nil })
+}
+
+func TestLDAPLoginCreatesDirectoryManagedUser(t *testing.T) {
+	setupTestLDAP(t)
+
+	user, err := Authenticate("alice", "directory-password", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if user.Email != "alice@example.com" || user.FirstName != "Alice" || user.LastName != "Liddell" {
+		t.Errorf("user = %q %q %q, want the directory entry's profile", user.Email, user.FirstName, user.LastName)
+	}
+	if err := LoadUserRoles(user); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(user.RoleNames(), models.RoleAdmin) {
+		t.Errorf("roles = %v, want the admin role mapped from the group", user.RoleNames())
+	}
+	if managed, err := isDirectoryManaged(user); err != nil || !managed {
+		t.Errorf("isDirectoryManaged = %v, %v, want true", managed, err)
+	}
+}
+
+func TestLDAPLoginRejectsWrongPassword(t *testing.T) {
+	setupTestLDAP(t)
+
+	if _, err := Authenticate("alice", "wrong-password", "127.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestDirectoryManagedUserCannotUseLocalCredentials(t *testing.T) {
+	setupTestLDAP(t)
+
+	user, err := Authenticate("alice", "directory-password", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	hashedPassword, err := passwords.Hash("local-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Model(user).Update("password", hashedPassword).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Authenticate("alice", "local-password", "127.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate with the local password = %v, want ErrInvalidCredentials", err)
+	}
+	if err := ChangePassword(user, "local-password", "another-local-password"); !errors.Is(err, ErrDirectoryManaged) {
+		t.Errorf("ChangePassword = %v, want ErrDirectoryManaged", err)
+	}
+
+	if err := RequestPasswordReset("alice@example.com", "127.0.0.1"); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	if err := RequestMagicLink("alice", "127.0.0.1"); err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	var resets, links int64
+	database.DB.Model(&models.PasswordResetToken{}).Count(&resets)
+	database.DB.Model(&models.MagicLinkToken{}).Count(&links)
+	if resets != 0 || links != 0 {
+		t.Errorf("issued %d password reset and %d magic link tokens, want none", resets, links)
+	}
+}
+
+func TestLDAPOutageFallsThroughToDatabase(t *testing.T) {
+	setupTestLDAP(t)
+	if _, err := Authenticate("alice", "directory-password", "127.0.0.1"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if _, err := RegisterUser(NewUser{
+		Username:      "bob",
+		Email:         "bob@example.com",
+		Password:      "correct horse battery staple",
+		EmailVerified: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	setConfig(t, map[string]string{"LDAP_URL": "ldap://" + listener.Addr().String()})
+	if err := InitAuthenticators(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Authenticate("bob", "correct horse battery staple", "127.0.0.1"); err != nil {
+		t.Errorf("Authenticate local user = %v, want success", err)
+	}
+	if _, err := Authenticate("alice", "directory-password", "127.0.0.1"); !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("Authenticate directory user = %v, want ErrBackendUnavailable", err)
+	}
+}
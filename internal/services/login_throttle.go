@@ -0,0 +1,176 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const loginThrottlePruneInterval = time.Hour
+
+// LockoutError is returned while too many failed logins lock the account or
+// the client IP.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, as used
+// by the Retry-After header.
+func (e *LockoutError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// InitLoginThrottle starts removing throttles that no longer lock anything
+// and whose failures have been forgotten.
+func InitLoginThrottle() {
+	go func() {
+		ticker := time.NewTicker(loginThrottlePruneInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := PruneLoginThrottles(); err != nil {
+				log.Printf("Error pruning login throttles: %v", err)
+			}
+		}
+	}()
+}
+
+// Accounts are throttled by their username, or by the submitted identifier
+// when it matches no user, so that locks do not reveal which usernames are
+// registered.
+func accountThrottleID(username string) string {
+	return models.LoginThrottleAccount + ":" + models.NormalizeIdentifier(username)
+}
+
+func ipThrottleID(ip string) string {
+	return models.LoginThrottleIP + ":" + ip
+}
+
+// CheckLoginThrottle returns a *LockoutError if the account or the IP is
+// locked.
+func CheckLoginThrottle(username string, ip string) error {
+	var throttles []models.LoginThrottle
+	err := database.DB.Where("identifier IN ?", []string{accountThrottleID(username), ipThrottleID(ip)}).
+		Find(&throttles).Error
+	if err != nil {
+		return err
+	}
+
+	var retryAfter time.Duration
+	for _, throttle := range throttles {
+		if throttle.IsLocked() {
+			retryAfter = max(retryAfter, time.Until(*throttle.LockedUntil))
+		}
+	}
+	if retryAfter > 0 {
+		return &LockoutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordLoginFailure counts a failed login against the account and the IP.
+// It returns a *LockoutError if this failure locked either of them.
+func RecordLoginFailure(username string, ip string) error {
+	accountThreshold, ipThreshold := config.GetLockoutThresholds()
+
+	accountLock, err := recordThrottleFailure(accountThrottleID(username), models.LoginThrottleAccount, accountThreshold)
+	if err != nil {
+		return err
+	}
+	ipLock, err := recordThrottleFailure(ipThrottleID(ip), models.LoginThrottleIP, ipThreshold)
+	if err != nil {
+		return err
+	}
+
+	if retryAfter := max(accountLock, ipLock); retryAfter > 0 {
+		return &LockoutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordLoginSuccess forgets the account's failed logins. The IP keeps its
+// count so that a valid login does not reset an attack from the same address.
+func RecordLoginSuccess(username string) error {
+	return deleteLoginThrottle(accountThrottleID(username))
+}
+
+// UnlockAccount lifts the lock on the user's account and forgets its failed
+// logins.
+func UnlockAccount(user *models.User) error {
+	return deleteLoginThrottle(accountThrottleID(user.Username))
+}
+
+// PruneLoginThrottles deletes throttles that are unlocked and whose last
+// failure is too old to count towards the threshold or the lock duration.
+func PruneLoginThrottles() error {
+	_, maxDuration := config.GetLockoutDuration()
+	now := time.Now()
+	cutoff := now.Add(-max(config.GetLockoutWindow(), maxDuration))
+	return database.DB.Unscoped().
+		Where("(locked_until IS NULL OR locked_until < ?) AND last_failure_at < ?", now, cutoff).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// recordThrottleFailure increments the failure count and locks the identifier
+// once it reaches the threshold. Each lock lasts twice as long as the
+// previous one, up to the configured maximum; the doubling starts over once
+// the identifier has been quiet for that maximum. It returns the duration of
+// the lock it applied, if any.
+func recordThrottleFailure(identifier string, kind string, threshold int) (time.Duration, error) {
+	baseDuration, maxDuration := config.GetLockoutDuration()
+	now := time.Now()
+
+	var lockedFor time.Duration
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, then lock it, so that concurrent failures
+		// neither race to insert it nor overwrite each other's counts.
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Identifier: identifier, Kind: kind}).Error
+		if err != nil {
+			return err
+		}
+		var throttle models.LoginThrottle
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("identifier = ?", identifier).First(&throttle).Error
+		if err != nil {
+			return err
+		}
+
+		if now.Sub(throttle.LastFailureAt) > config.GetLockoutWindow() {
+			throttle.Failures = 0
+		}
+		if now.Sub(throttle.LastFailureAt) > maxDuration {
+			throttle.LockCount = 0
+		}
+
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		if throttle.Failures >= threshold {
+			lockedFor = baseDuration
+			for i := 0; i < throttle.LockCount && lockedFor < maxDuration; i++ {
+				lockedFor *= 2
+			}
+			lockedFor = min(lockedFor, maxDuration)
+
+			lockedUntil := now.Add(lockedFor)
+			throttle.LockedUntil = &lockedUntil
+			throttle.LockCount++
+			throttle.Failures = 0
+		}
+		return tx.Save(&throttle).Error
+	})
+	return lockedFor, err
+}
+
+func deleteLoginThrottle(identifier string) error {
+	return database.DB.Unscoped().Where("identifier = ?", identifier).Delete(&models.LoginThrottle{}).Error
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"sync"
+	"testing"
+)
+
+func TestRecordThrottleFailureCountsConcurrentFailures(t *testing.T) {
+	setupTestDB(t)
+
+	const failures = 10
+	identifier := ipThrottleID("192.0.2.1")
+	var wg sync.WaitGroup
+	errs := make(chan error, failures)
+	for range failures {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := recordThrottleFailure(identifier, models.LoginThrottleIP, 100)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("recordThrottleFailure: %v", err)
+		}
+	}
+
+	var throttle models.LoginThrottle
+	if err := database.DB.Where("identifier = ?", identifier).First(&throttle).Error; err != nil {
+		t.Fatal(err)
+	}
+	if throttle.Failures != failures {
+		t.Errorf("Failures = %d, want %d", throttle.Failures, failures)
+	}
+}
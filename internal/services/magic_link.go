@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/mail"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const magicLinkTokenBytes = 32
+
+var ErrMagicLinkInvalid = errors.New("magic link is invalid or expired")
+
+// RequestMagicLink emails a login link if the username or email address
+// belongs to an active user whose password is not managed by the directory.
+// Other identifiers are ignored without an error, so callers cannot learn
+// which accounts exist. Accounts that are locked or
+// were already sent MAGIC_LINK_MAX_PER_HOUR links in the last hour are
+// skipped the same way. Issuing a link invalidates the user's earlier ones.
+func RequestMagicLink(identifier string, ip string) error {
+	user, err := FindUserByIdentifier(identifier)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !user.IsActive() {
+		return nil
+	}
+	if managed, err := isDirectoryManaged(user); err != nil || managed {
+		return err
+	}
+
+	var lockout *LockoutError
+	if err := CheckLoginThrottle(user.Username, ip); errors.As(err, &lockout) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var sent int64
+	err = database.DB.Model(&models.MagicLinkToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-time.Hour)).
+		Count(&sent).Error
+	if err != nil {
+		return err
+	}
+	if sent >= int64(config.GetMagicLinkMaxPerHour()) {
+		log.Printf("Not sending magic link to user %s: hourly limit reached", user.UID)
+		return nil
+	}
+
+	raw, err := utils.GenerateOpaqueToken(magicLinkTokenBytes)
+	if err != nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := invalidateMagicLinkTokens(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Create(&models.MagicLinkToken{
+			UserID:      user.ID,
+			TokenHash:   utils.HashToken(raw),
+			ExpiresAt:   time.Now().Add(config.GetMagicLinkTTL()),
+			CreatedByIP: ip,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(config.GetMagicLinkURL(), raw)
+	if err != nil {
+		return err
+	}
+
+	return mail.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to sign in. It expires in %s and can only be used once.\n\n%s\n\nIf you did not ask to sign in, you can ignore this email.\n",
+			user.Username, config.GetMagicLinkTTL(), link),
+	})
+}
+
+// RedeemMagicLink consumes a magic link token and returns the user it signs
+// in. While the account or the client IP is locked it returns a
+// *LockoutError and leaves the token usable.
+func RedeemMagicLink(raw string, ip string) (*models.User, error) {
+	token := new(models.MagicLinkToken)
+	err := database.DB.Where("token_hash = ?", utils.HashToken(raw)).First(token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMagicLinkInvalid
+	} else if err != nil {
+		return nil, err
+	}
+	if token.UsedAt != nil || token.IsExpired() {
+		return nil, ErrMagicLinkInvalid
+	}
+
+	user := new(models.User)
+	if err := database.DB.First(user, token.UserID).Error; err != nil {
+		return nil, err
+	}
+	if managed, err := isDirectoryManaged(user); err != nil {
+		return nil, err
+	} else if managed {
+		return nil, ErrMagicLinkInvalid
+	}
+	if err := CheckLoginThrottle(user.Username, ip); err != nil {
+		return nil, err
+	}
+
+	update := database.DB.Model(&models.MagicLinkToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", time.Now())
+	if update.Error != nil {
+		return nil, update.Error
+	}
+	if update.RowsAffected == 0 {
+		return nil, ErrMagicLinkInvalid
+	}
+
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+	if err := RecordLoginSuccess(user.Username); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func invalidateMagicLinkTokens(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.MagicLinkToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount       = 10
+	mfaChallengeBytes       = 32
+	maxMFAChallengeAttempts = 5
+)
+
+var (
+	ErrMFAAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled     = errors.New("two-factor authentication is not enrolled")
+	ErrMFACodeInvalid     = errors.New("authentication code is invalid")
+	ErrMFAChallengeFailed = errors.New("MFA challenge is invalid or expired")
+)
+
+// MFARequired reports whether the user must provide a second factor, or
+// enroll one first, before being issued tokens.
+func MFARequired(user *models.User) bool {
+	return user.MFAEnabled || MFAEnrollmentRequired(user)
+}
+
+// MFAMandatory reports whether the user may not log in without MFA, which is
+// the case for admins when MFA_REQUIRED_FOR_ADMINS is set. Users whose roles
+// cannot be loaded are treated as admins.
+func MFAMandatory(user *models.User) bool {
+	if !config.IsMFARequiredForAdmins() {
+		return false
+	}
+	if err := ensureUserRoles(user); err != nil {
+		log.Printf("Error loading roles of user %s: %v", user.UID, err)
+		return true
+	}
+	return user.IsAdmin()
+}
+
+// MFAEnrollmentRequired reports whether the user must enroll before logging
+// in.
+func MFAEnrollmentRequired(user *models.User) bool {
+	return !user.MFAEnabled && MFAMandatory(user)
+}
+
+// BeginTOTPEnrollment stores a new unconfirmed TOTP secret for the user and
+// returns it with its provisioning URI.
+func BeginTOTPEnrollment(user *models.User) (string, string, error) {
+	if user.MFAEnabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := utils.Encrypt([]byte(secret))
+	if err != nil {
+		return "", "", err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.TOTPCredential{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TOTPCredential{UserID: user.ID, Secret: encrypted}).Error
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	uri := utils.TOTPProvisioningURI(config.GetMFAIssuer(), user.Username, secret)
+	return secret, uri, nil
+}
+
+// ConfirmTOTPEnrollment enables MFA once the user proves the authenticator
+// produces valid codes, and returns a fresh set of recovery codes.
+func ConfirmTOTPEnrollment(user *models.User, code string) ([]string, error) {
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	credential, err := findTOTPCredential(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyTOTP(credential, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(credential).Update("confirmed_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(user).Update("mfa_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA removes the user's TOTP enrollment and recovery codes.
+func DisableMFA(user *models.User) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.TOTPCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(user).Update("mfa_enabled", false).Error
+	})
+}
+
+// RegenerateRecoveryCodes invalidates the user's recovery codes and returns
+// new ones.
+func RegenerateRecoveryCodes(user *models.User) ([]string, error) {
+	if !user.MFAEnabled {
+		return nil, ErrMFANotEnrolled
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user)
+		return err
+	})
+	return codes, err
+}
+
+// VerifyMFACode accepts either a current TOTP code or an unused recovery
+// code, which is consumed.
+func VerifyMFACode(user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrMFACodeInvalid
+	}
+
+	credential, err := findTOTPCredential(user)
+	if err != nil {
+		return err
+	}
+	if !credential.IsConfirmed() {
+		return ErrMFANotEnrolled
+	}
+	if len(code) == 6 {
+		return verifyTOTP(credential, code)
+	}
+	return useRecoveryCode(user, code)
+}
+
+func findTOTPCredential(user *models.User) (*models.TOTPCredential, error) {
+	credential := new(models.TOTPCredential)
+	err := database.DB.Where("user_id = ?", user.ID).First(credential).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMFANotEnrolled
+	}
+	return credential, err
+}
+
+func verifyTOTP(credential *models.TOTPCredential, code string) error {
+	secret, err := utils.Decrypt(credential.Secret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := utils.ValidateTOTP(string(secret), code, time.Now())
+	if !ok || step <= credential.LastUsedStep {
+		return ErrMFACodeInvalid
+	}
+
+	update := database.DB.Model(&models.TOTPCredential{}).
+		Where("id = ? AND last_used_step < ?", credential.ID, step).
+		Update("last_used_step", step)
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return ErrMFACodeInvalid
+	}
+	return nil
+}
+
+func useRecoveryCode(user *models.User, code string) error {
+	update := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return ErrMFACodeInvalid
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, user *models.User) ([]string, error) {
+	if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		record := &models.RecoveryCode{UserID: user.ID, CodeHash: utils.HashToken(normalizeRecoveryCode(code))}
+		if err := tx.Create(record).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code such as "k3f9x-7qm2p" drawn from an
+// alphabet without easily confused characters.
+func generateRecoveryCode() (string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	const limit = 256 - 256%len(alphabet)
+
+	code := make([]byte, 0, 10)
+	b := make([]byte, 1)
+	for len(code) < cap(code) {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		// Reject bytes that would bias the modulo towards the first letters.
+		if int(b[0]) < limit {
+			code = append(code, alphabet[int(b[0])%len(alphabet)])
+		}
+	}
+	return string(code[:5]) + "-" + string(code[5:]), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(code)), "-", "")
+}
+
+// CreateMFAChallenge issues the token the client presents with the second
+// factor to finish logging in.
+func CreateMFAChallenge(user *models.User, purpose string) (string, *models.MFAChallenge, error) {
+	raw, err := utils.GenerateOpaqueToken(mfaChallengeBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	challenge := &models.MFAChallenge{
+		TokenHash: utils.HashToken(raw),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(config.GetMFAChallengeTTL()),
+	}
+	if err := database.DB.Create(challenge).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, challenge, nil
+}
+
+// FindMFAChallenge returns a usable challenge of the given purpose and its
+// user.
+func FindMFAChallenge(raw string, purpose string) (*models.MFAChallenge, *models.User, error) {
+	challenge := new(models.MFAChallenge)
+	err := database.DB.Where("token_hash = ? AND purpose = ?", utils.HashToken(raw), purpose).First(challenge).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrMFAChallengeFailed
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if challenge.UsedAt != nil || challenge.IsExpired() || challenge.Attempts >= maxMFAChallengeAttempts {
+		return nil, nil, ErrMFAChallengeFailed
+	}
+
+	user := new(models.User)
+	if err := database.DB.First(user, challenge.UserID).Error; err != nil {
+		return nil, nil, err
+	}
+	return challenge, user, nil
+}
+
+// RecordMFAChallengeFailure counts a wrong code against the challenge.
+func RecordMFAChallengeFailure(challenge *models.MFAChallenge) error {
+	return database.DB.Model(challenge).Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// CompleteMFAChallenge marks the challenge as used. It fails if another
+// request already redeemed it.
+func CompleteMFAChallenge(challenge *models.MFAChallenge) error {
+	update := database.DB.Model(&models.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL", challenge.ID).
+		Update("used_at", time.Now())
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return ErrMFAChallengeFailed
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+// enrollTOTP enables TOTP for the user and returns the secret, the step of
+// the code that confirmed it and the recovery codes.
+func enrollTOTP(t *testing.T, user *models.User) (string, int64, []string) {
+	t.Helper()
+	secret, _, err := BeginTOTPEnrollment(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := utils.TOTPStep(time.Now())
+	code, err := utils.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoveryCodes, err := ConfirmTOTPEnrollment(user, code)
+	if err != nil {
+		t.Fatalf("ConfirmTOTPEnrollment: %v", err)
+	}
+	return secret, step, recoveryCodes
+}
+
+func TestTOTPCodesCannotBeReplayed(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	secret, step, _ := enrollTOTP(t, user)
+	if !user.MFAEnabled {
+		t.Fatal("MFA is not enabled after confirming the enrollment")
+	}
+
+	code, _ := utils.TOTPCode(secret, step)
+	if err := VerifyMFACode(user, code); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Errorf("VerifyMFACode with the enrollment code = %v, want ErrMFACodeInvalid", err)
+	}
+
+	next, _ := utils.TOTPCode(secret, step+1)
+	if err := VerifyMFACode(user, next); err != nil {
+		t.Fatalf("VerifyMFACode with the next code: %v", err)
+	}
+	if err := VerifyMFACode(user, next); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Errorf("VerifyMFACode replaying the next code = %v, want ErrMFACodeInvalid", err)
+	}
+	if err := VerifyMFACode(user, code); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Errorf("VerifyMFACode with an earlier code = %v, want ErrMFACodeInvalid", err)
+	}
+
+	stale, _ := utils.TOTPCode(secret, step-5)
+	if err := VerifyMFACode(user, stale); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Errorf("VerifyMFACode with a stale code = %v, want ErrMFACodeInvalid", err)
+	}
+}
+
+func TestConfirmTOTPEnrollmentRejectsWrongCode(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	secret, _, err := BeginTOTPEnrollment(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := utils.TOTPCode(secret, utils.TOTPStep(time.Now())-5)
+
+	if _, err := ConfirmTOTPEnrollment(user, code); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Errorf("ConfirmTOTPEnrollment = %v, want ErrMFACodeInvalid", err)
+	}
+	if err := VerifyMFACode(user, code); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Errorf("VerifyMFACode before confirming = %v, want ErrMFANotEnrolled", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	_, _, codes := enrollTOTP(t, user)
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// Codes are accepted however the user types them, but only once.
+	if err := VerifyMFACode(user, " "+strings.ToUpper(codes[0])+" "); err != nil {
+		t.Fatalf("VerifyMFACode with a recovery code: %v", err)
+	}
+	if err := VerifyMFACode(user, codes[0]); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Errorf("VerifyMFACode reusing a recovery code = %v, want ErrMFACodeInvalid", err)
+	}
+	if err := VerifyMFACode(user, strings.ReplaceAll(codes[1], "-", "")); err != nil {
+		t.Errorf("VerifyMFACode without the dash: %v", err)
+	}
+
+	fresh, err := RegenerateRecoveryCodes(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyMFACode(user, codes[2]); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Errorf("VerifyMFACode with a replaced recovery code = %v, want ErrMFACodeInvalid", err)
+	}
+	if err := VerifyMFACode(user, fresh[0]); err != nil {
+		t.Errorf("VerifyMFACode with a regenerated recovery code: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	authorizationCodeTTL   = time.Minute
+	authorizationCodeBytes = 32
+	clientSecretBytes      = 32
+)
+
+var (
+	ErrInvalidClient = errors.New("client authentication failed")
+	ErrInvalidGrant  = errors.New("authorization grant is invalid")
+	ErrUnknownScope  = errors.New("unknown scope")
+	ErrScopeNotHeld  = errors.New("scope is not held by the caller")
+
+	ErrSymmetricIDTokens = errors.New("ID tokens are signed with a shared secret")
+)
+
+// CreateOAuthClient registers a client on behalf of the creator, who must
+// hold every permission granted to it as a scope. The returned secret is only
+// available at creation time and is empty for public clients.
+func CreateOAuthClient(name string, redirectURIs []string, scopes []string, grantTypes []string, audience string, public bool, creator *models.JwtCustomClaims) (*models.OAuthClient, string, error) {
+	// Clients cannot verify ID tokens signed with our shared secret, and must
+	// not be given it.
+	if slices.Contains(scopes, "openid") {
+		if key := utils.Keys.Current(); key == nil || key.IsSymmetric() {
+			return nil, "", ErrSymmetricIDTokens
+		}
+	}
+	if err := checkClientScopes(scopes, creator); err != nil {
+		return nil, "", err
+	}
+
+	client := &models.OAuthClient{
+		ClientID:     uuid.NewString(),
+		Name:         name,
+		RedirectURIs: strings.Join(redirectURIs, " "),
+		Scopes:       strings.Join(scopes, " "),
+		GrantTypes:   strings.Join(grantTypes, " "),
+		Audience:     audience,
+	}
+
+	var secret string
+	if !public {
+		var err error
+		secret, err = utils.GenerateOpaqueToken(clientSecretBytes)
+		if err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	if err := database.DB.Create(client).Error; err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// checkClientScopes returns ErrUnknownScope unless every scope is an OpenID
+// Connect scope, the admin scope or an existing permission, and
+// ErrScopeNotHeld if the creator does not hold a permission. The admin scope
+// holds every permission, so only users with the admin role may grant it.
+func checkClientScopes(scopes []string, creator *models.JwtCustomClaims) error {
+	var permissions []string
+	for _, scope := range scopes {
+		if scope != models.ScopeAdmin && !slices.Contains(models.OIDCScopes, scope) {
+			permissions = append(permissions, scope)
+		}
+	}
+	if _, err := findPermissions(permissions); errors.Is(err, ErrPermissionNotFound) {
+		return ErrUnknownScope
+	} else if err != nil {
+		return err
+	}
+
+	if slices.Contains(scopes, models.ScopeAdmin) && (creator.IsService() || !slices.Contains(creator.Roles, models.RoleAdmin)) {
+		return fmt.Errorf("%w: %s", ErrScopeNotHeld, models.ScopeAdmin)
+	}
+	for _, permission := range permissions {
+		if !creator.HasPermission(permission) {
+			return fmt.Errorf("%w: %s", ErrScopeNotHeld, permission)
+		}
+	}
+	return nil
+}
+
+func FindOAuthClient(clientID string) (*models.OAuthClient, error) {
+	client := new(models.OAuthClient)
+	if err := database.DB.Where("client_id = ?", clientID).First(client).Error; err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// DeleteOAuthClient removes the client, revokes its refresh tokens and the
+// access tokens it holds as a service principal.
+func DeleteOAuthClient(client *models.OAuthClient) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.RefreshToken{}).
+			Where("client_id = ? AND revoked_at IS NULL", client.ClientID).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(client).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if client.AllowsGrantType(models.GrantTypeClientCredentials) {
+		return Revocations.RevokeUser(client.ClientID)
+	}
+	return nil
+}
+
+// AuthenticateOAuthClient verifies the client credentials. Public clients
+// authenticate with their client_id alone.
+func AuthenticateOAuthClient(clientID string, secret string) (*models.OAuthClient, error) {
+	client, err := FindOAuthClient(clientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidClient
+	} else if err != nil {
+		return nil, err
+	}
+
+	if client.IsPublic() {
+		if secret != "" {
+			return nil, ErrInvalidClient
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// CreateAuthorizationCode issues a code for the user's consent to the request.
+func CreateAuthorizationCode(client *models.OAuthClient, user *models.User, redirectURI string, scope string, codeChallenge string, nonce string) (string, error) {
+	raw, err := utils.GenerateOpaqueToken(authorizationCodeBytes)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	code := &models.AuthorizationCode{
+		CodeHash:      utils.HashToken(raw),
+		ClientID:      client.ClientID,
+		UserID:        user.ID,
+		RedirectURI:   redirectURI,
+		Scope:         scope,
+		CodeChallenge: codeChallenge,
+		Nonce:         nonce,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(authorizationCodeTTL),
+	}
+	if err := database.DB.Create(code).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ExchangeAuthorizationCode redeems a code for the client, checking the
+// redirect URI and the PKCE S256 code verifier.
+func ExchangeAuthorizationCode(raw string, client *models.OAuthClient, redirectURI string, codeVerifier string) (*models.AuthorizationCode, error) {
+	code := new(models.AuthorizationCode)
+	result := database.DB.Where("code_hash = ?", utils.HashToken(raw)).First(code)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidGrant
+	} else if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != redirectURI || code.IsExpired() {
+		return nil, ErrInvalidGrant
+	}
+	if !VerifyCodeChallenge(code.CodeChallenge, codeVerifier) {
+		return nil, ErrInvalidGrant
+	}
+
+	update := database.DB.Model(&models.AuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", code.ID).
+		Update("used_at", time.Now())
+	if update.Error != nil {
+		return nil, update.Error
+	}
+	if update.RowsAffected == 0 {
+		return nil, ErrInvalidGrant
+	}
+	return code, nil
+}
+
+// VerifyCodeChallenge checks an RFC 7636 S256 code verifier.
+func VerifyCodeChallenge(challenge string, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	hash := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(hash[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestCreateOAuthClientRefusesScopesTheCreatorDoesNotHold(t *testing.T) {
+	setupTestDB(t)
+	useSigningKey(t, "RS256")
+	creator := &models.JwtCustomClaims{
+		UserID:      "client-manager",
+		Roles:       []string{"client-manager"},
+		Permissions: []string{models.PermissionClientsWrite},
+	}
+
+	for _, scopes := range [][]string{{models.ScopeAdmin}, {models.PermissionUsersWrite}, {"openid", models.PermissionRolesWrite}} {
+		if _, _, err := CreateOAuthClient("worker", nil, scopes, []string{models.GrantTypeClientCredentials}, "", false, creator); !errors.Is(err, ErrScopeNotHeld) {
+			t.Errorf("CreateOAuthClient with scopes %v = %v, want ErrScopeNotHeld", scopes, err)
+		}
+	}
+	if _, _, err := CreateOAuthClient("worker", nil, []string{"anything"}, nil, "", false, creator); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("CreateOAuthClient with an unknown scope = %v, want ErrUnknownScope", err)
+	}
+
+	client, _, err := CreateOAuthClient("worker", nil, []string{"openid", models.PermissionClientsWrite}, nil, "", false, creator)
+	if err != nil {
+		t.Fatalf("CreateOAuthClient with held scopes: %v", err)
+	}
+	if client.Scopes != "openid clients:write" {
+		t.Errorf("Scopes = %q, want the requested scopes", client.Scopes)
+	}
+}
+
+func TestCreateOAuthClientAdminScope(t *testing.T) {
+	setupTestDB(t)
+	admin := &models.JwtCustomClaims{UserID: "admin", Roles: []string{models.RoleAdmin}}
+	service := &models.JwtCustomClaims{ClientID: "service", PrincipalType: models.PrincipalService, Scope: models.ScopeAdmin}
+
+	if _, _, err := CreateOAuthClient("worker", nil, []string{models.ScopeAdmin}, nil, "", false, admin); err != nil {
+		t.Errorf("CreateOAuthClient by an admin: %v", err)
+	}
+	if _, _, err := CreateOAuthClient("worker", nil, []string{models.ScopeAdmin}, nil, "", false, service); !errors.Is(err, ErrScopeNotHeld) {
+		t.Errorf("CreateOAuthClient by a service principal = %v, want ErrScopeNotHeld", err)
+	}
+}
+
+func TestCreateOAuthClientRefusesOpenIDWithSharedSecret(t *testing.T) {
+	setupTestDB(t)
+	admin := &models.JwtCustomClaims{UserID: "admin", Roles: []string{models.RoleAdmin}}
+
+	useSigningKey(t, "HS256")
+	if _, _, err := CreateOAuthClient("app", nil, []string{"openid"}, nil, "", false, admin); !errors.Is(err, ErrSymmetricIDTokens) {
+		t.Errorf("CreateOAuthClient with HS256 = %v, want ErrSymmetricIDTokens", err)
+	}
+	if _, _, err := CreateOAuthClient("worker", nil, []string{models.ScopeAdmin}, nil, "", false, admin); err != nil {
+		t.Errorf("CreateOAuthClient without openid: %v", err)
+	}
+
+	useSigningKey(t, "ES256")
+	if _, _, err := CreateOAuthClient("app", nil, []string{"openid"}, nil, "", false, admin); err != nil {
+		t.Errorf("CreateOAuthClient with ES256: %v", err)
+	}
+}
+
+// testCodeVerifier and testCodeChallenge are an RFC 7636 S256 pair.
+const (
+	testCodeVerifier  = "M25iVXpKU3puUjFaYWg3T1NDTDQtcW1ROUY5YXlwalNoc0hhakxifmZHag"
+	testCodeChallenge = "qjrzSW9gMiUgpUvqgEPE4_-8swvyCtfOVvg55o5S_es"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		verifier  string
+		want      bool
+	}{
+		{"matching verifier", testCodeChallenge, testCodeVerifier, true},
+		{"other verifier", testCodeChallenge, testCodeVerifier[1:] + "x", false},
+		{"plain challenge", testCodeVerifier, testCodeVerifier, false},
+		{"short verifier", "LPJNul-wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ", "hello", false},
+		{"no challenge", "", testCodeVerifier, false},
+	}
+	for _, tt := range tests {
+		if got := VerifyCodeChallenge(tt.challenge, tt.verifier); got != tt.want {
+			t.Errorf("%s: VerifyCodeChallenge = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExchangeAuthorizationCode(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	client := &models.OAuthClient{ClientID: "app"}
+	const redirectURI = "https://app.example.com/callback"
+
+	code, err := CreateAuthorizationCode(client, user, redirectURI, "openid", testCodeChallenge, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := []struct {
+		name        string
+		client      *models.OAuthClient
+		redirectURI string
+		verifier    string
+	}{
+		{"another client", &models.OAuthClient{ClientID: "other"}, redirectURI, testCodeVerifier},
+		{"another redirect URI", client, "https://app.example.com/other", testCodeVerifier},
+		{"a wrong verifier", client, redirectURI, testCodeVerifier[1:] + "x"},
+		{"no verifier", client, redirectURI, ""},
+	}
+	for _, tt := range rejected {
+		if _, err := ExchangeAuthorizationCode(code, tt.client, tt.redirectURI, tt.verifier); !errors.Is(err, ErrInvalidGrant) {
+			t.Errorf("ExchangeAuthorizationCode with %s = %v, want ErrInvalidGrant", tt.name, err)
+		}
+	}
+
+	grant, err := ExchangeAuthorizationCode(code, client, redirectURI, testCodeVerifier)
+	if err != nil {
+		t.Fatalf("ExchangeAuthorizationCode: %v", err)
+	}
+	if grant.UserID != user.ID || grant.Scope != "openid" || grant.Nonce != "nonce" {
+		t.Errorf("grant = %+v, want the user's consent", grant)
+	}
+	if _, err := ExchangeAuthorizationCode(code, client, redirectURI, testCodeVerifier); !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("second ExchangeAuthorizationCode = %v, want ErrInvalidGrant", err)
+	}
+}
+
+func TestExchangeExpiredAuthorizationCode(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	client := &models.OAuthClient{ClientID: "app"}
+	code, err := CreateAuthorizationCode(client, user, "https://app.example.com/callback", "", testCodeChallenge, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = database.DB.Model(&models.AuthorizationCode{}).
+		Where("code_hash = ?", utils.HashToken(code)).
+		Update("expires_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ExchangeAuthorizationCode(code, client, "https://app.example.com/callback", testCodeVerifier); !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("ExchangeAuthorizationCode of an expired code = %v, want ErrInvalidGrant", err)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/mail"
+	"platform-service/internal/models"
+	"platform-service/internal/passwords"
+	"platform-service/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const passwordResetTokenBytes = 32
+
+var (
+	ErrPasswordResetTokenInvalid = errors.New("password reset token is invalid or expired")
+	ErrPasswordIncorrect         = errors.New("current password is incorrect")
+)
+
+// RequestPasswordReset emails a reset link if the address belongs to an
+// account that may log in and whose password is not managed by the
+// directory. Other addresses are ignored without an error, so
+// callers cannot learn which addresses are registered. Issuing a token
+// invalidates the user's earlier ones.
+func RequestPasswordReset(email string, ip string) error {
+	user := new(models.User)
+	err := database.DB.Where("normalized_email = ?", models.NormalizeIdentifier(email)).First(user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !user.IsActive() && !user.IsPendingVerification() {
+		return nil
+	}
+	if managed, err := isDirectoryManaged(user); err != nil || managed {
+		return err
+	}
+
+	raw, err := utils.GenerateOpaqueToken(passwordResetTokenBytes)
+	if err != nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := invalidatePasswordResetTokens(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:      user.ID,
+			TokenHash:   utils.HashToken(raw),
+			ExpiresAt:   time.Now().Add(config.GetPasswordResetTTL()),
+			CreatedByIP: ip,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(config.GetPasswordResetURL(), raw)
+	if err != nil {
+		return err
+	}
+
+	return mail.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nWe received a request to reset your password. Open the link below to choose a new one. It expires in %s and can only be used once.\n\n%s\n\nIf you did not request a reset, you can ignore this email; your password has not been changed.\n",
+			user.Username, config.GetPasswordResetTTL(), link),
+	})
+}
+
+// ResetPassword redeems a reset token, sets the new password and revokes all
+// of the user's tokens so that existing sessions have to log in again. A
+// password that breaks the policy returns a *passwords.PolicyError and leaves
+// the token usable.
+func ResetPassword(raw string, password string) (*models.User, error) {
+	token := new(models.PasswordResetToken)
+	err := database.DB.Where("token_hash = ?", utils.HashToken(raw)).First(token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPasswordResetTokenInvalid
+	} else if err != nil {
+		return nil, err
+	}
+	if token.UsedAt != nil || token.IsExpired() {
+		return nil, ErrPasswordResetTokenInvalid
+	}
+
+	user := new(models.User)
+	if err := database.DB.First(user, token.UserID).Error; err != nil {
+		return nil, err
+	}
+	if managed, err := isDirectoryManaged(user); err != nil {
+		return nil, err
+	} else if managed {
+		return nil, ErrPasswordResetTokenInvalid
+	}
+	if err := passwords.Check(password, user.Username, user.Email); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := passwords.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		update := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", time.Now())
+		if update.Error != nil {
+			return update.Error
+		}
+		if update.RowsAffected == 0 {
+			return ErrPasswordResetTokenInvalid
+		}
+
+		if err := tx.Model(user).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		return invalidatePasswordResetTokens(tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RevokeAllUserTokens(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func invalidatePasswordResetTokens(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// ChangePassword replaces the password of a user who knows the current one
+// and revokes all of the user's tokens.
+func ChangePassword(user *models.User, currentPassword string, newPassword string) error {
+	if managed, err := isDirectoryManaged(user); err != nil {
+		return err
+	} else if managed {
+		return ErrDirectoryManaged
+	}
+
+	ok, _, err := passwords.Verify(user.Password, currentPassword)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPasswordIncorrect
+	}
+	if err := passwords.Check(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := passwords.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := database.DB.Model(user).Update("password", hashedPassword).Error; err != nil {
+		return err
+	}
+	return RevokeAllUserTokens(user)
+}
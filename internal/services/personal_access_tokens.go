@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	personalAccessTokenBytes = 32
+	personalAccessTokenHint  = len(models.PersonalAccessTokenPrefix) + 6
+
+	// Last use is recorded at most this often per token, so that busy
+	// scripts do not write on every request.
+	personalAccessTokenTouchInterval = time.Minute
+)
+
+var (
+	ErrPersonalAccessTokenInvalid  = errors.New("personal access token is invalid")
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+	ErrInvalidScope                = errors.New("invalid scope")
+	ErrInvalidExpiry               = errors.New("expiry must be in the future")
+)
+
+// CreatePersonalAccessToken issues a token for the user. The returned raw
+// token is not stored and cannot be shown again. Only admins may grant the
+// admin scope.
+func CreatePersonalAccessToken(user *models.User, name string, scopes []string, expiresAt *time.Time) (string, *models.PersonalAccessToken, error) {
+	if len(scopes) == 0 {
+		scopes = []string{models.ScopeRead}
+	}
+	if err := ensureUserRoles(user); err != nil {
+		return "", nil, err
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.PersonalAccessTokenScopes, scope) {
+			return "", nil, ErrInvalidScope
+		}
+		if scope == models.ScopeAdmin && !user.IsAdmin() {
+			return "", nil, ErrInvalidScope
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, ErrInvalidExpiry
+	}
+
+	secret, err := utils.GenerateOpaqueToken(personalAccessTokenBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	raw := models.PersonalAccessTokenPrefix + secret
+
+	token := &models.PersonalAccessToken{
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: utils.HashToken(raw),
+		Hint:      raw[:personalAccessTokenHint],
+		Scopes:    strings.Join(slices.Compact(slices.Sorted(slices.Values(scopes))), " "),
+		ExpiresAt: expiresAt,
+	}
+	if err := database.DB.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, token, nil
+}
+
+// AuthenticatePersonalAccessToken returns the active user a token belongs to,
+// with their roles loaded, and records its use.
+func AuthenticatePersonalAccessToken(raw string, ip string) (*models.User, *models.PersonalAccessToken, error) {
+	token := new(models.PersonalAccessToken)
+	err := database.DB.Where("token_hash = ?", utils.HashToken(raw)).First(token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrPersonalAccessTokenInvalid
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if token.IsRevoked() || token.IsExpired() {
+		return nil, nil, ErrPersonalAccessTokenInvalid
+	}
+
+	user := new(models.User)
+	err = database.DB.First(user, token.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrPersonalAccessTokenInvalid
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive() {
+		return nil, nil, ErrPersonalAccessTokenInvalid
+	}
+	if err := LoadUserRoles(user); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= personalAccessTokenTouchInterval {
+		err := database.DB.Model(token).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		}).Error
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return user, token, nil
+}
+
+// ListPersonalAccessTokens returns the user's tokens that are neither revoked
+// nor expired, newest first.
+func ListPersonalAccessTokens(user *models.User) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := database.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", user.ID, time.Now()).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokePersonalAccessToken revokes one of the user's tokens.
+func RevokePersonalAccessToken(user *models.User, id uint) error {
+	update := database.DB.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, user.ID).
+		Update("revoked_at", time.Now())
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+func revokeUserPersonalAccessTokens(userID uint) error {
+	return database.DB.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
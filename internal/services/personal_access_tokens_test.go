@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreatePersonalAccessTokenScopes(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	admin := registerTestUser(t, "root", models.RoleAdmin)
+
+	raw, token, err := CreatePersonalAccessToken(user, "default", nil, nil)
+	if err != nil {
+		t.Fatalf("CreatePersonalAccessToken: %v", err)
+	}
+	if !strings.HasPrefix(raw, models.PersonalAccessTokenPrefix) || token.Scopes != models.ScopeRead {
+		t.Errorf("token %q with scopes %q, want the prefix and the read scope", raw, token.Scopes)
+	}
+	if _, token, err = CreatePersonalAccessToken(user, "write", []string{models.ScopeWrite, models.ScopeRead, models.ScopeWrite}, nil); err != nil {
+		t.Fatalf("CreatePersonalAccessToken: %v", err)
+	} else if token.Scopes != "read write" {
+		t.Errorf("scopes = %q, want read write", token.Scopes)
+	}
+
+	if _, _, err := CreatePersonalAccessToken(user, "unknown", []string{"delete"}, nil); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("unknown scope: err = %v, want ErrInvalidScope", err)
+	}
+	if _, _, err := CreatePersonalAccessToken(user, "admin", []string{models.ScopeAdmin}, nil); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("admin scope for a non-admin: err = %v, want ErrInvalidScope", err)
+	}
+	if _, _, err := CreatePersonalAccessToken(admin, "admin", []string{models.ScopeAdmin}, nil); err != nil {
+		t.Errorf("admin scope for an admin: %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if _, _, err := CreatePersonalAccessToken(user, "expired", nil, &past); !errors.Is(err, ErrInvalidExpiry) {
+		t.Errorf("expiry in the past: err = %v, want ErrInvalidExpiry", err)
+	}
+}
+
+func TestAuthenticatePersonalAccessToken(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+
+	raw, token, err := CreatePersonalAccessToken(user, "cli", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticated, _, err := AuthenticatePersonalAccessToken(raw, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("AuthenticatePersonalAccessToken: %v", err)
+	}
+	if authenticated.ID != user.ID {
+		t.Errorf("authenticated user %d, want %d", authenticated.ID, user.ID)
+	}
+	if err := database.DB.First(token, token.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if token.LastUsedAt == nil || token.LastUsedIP != "127.0.0.1" {
+		t.Errorf("last use = %v from %q, want it recorded", token.LastUsedAt, token.LastUsedIP)
+	}
+
+	if _, _, err := AuthenticatePersonalAccessToken(raw+"x", ""); !errors.Is(err, ErrPersonalAccessTokenInvalid) {
+		t.Errorf("unknown token: err = %v, want ErrPersonalAccessTokenInvalid", err)
+	}
+
+	expiring := time.Now().Add(time.Hour)
+	expired, token, err := CreatePersonalAccessToken(user, "expired", nil, &expiring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Model(token).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := AuthenticatePersonalAccessToken(expired, ""); !errors.Is(err, ErrPersonalAccessTokenInvalid) {
+		t.Errorf("expired token: err = %v, want ErrPersonalAccessTokenInvalid", err)
+	}
+
+	if err := database.DB.Model(user).Update("status", models.UserStatusDisabled).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := AuthenticatePersonalAccessToken(raw, ""); !errors.Is(err, ErrPersonalAccessTokenInvalid) {
+		t.Errorf("disabled user: err = %v, want ErrPersonalAccessTokenInvalid", err)
+	}
+}
+
+func TestRevokePersonalAccessToken(t *testing.T) {
+	setupTestDB(t)
+	alice := registerTestUser(t, "alice")
+	bob := registerTestUser(t, "bob")
+
+	raw, token, err := CreatePersonalAccessToken(alice, "cli", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokePersonalAccessToken(bob, token.ID); !errors.Is(err, ErrPersonalAccessTokenNotFound) {
+		t.Fatalf("revoking another user's token: err = %v, want ErrPersonalAccessTokenNotFound", err)
+	}
+	if _, _, err := AuthenticatePersonalAccessToken(raw, ""); err != nil {
+		t.Fatalf("token revoked by another user: %v", err)
+	}
+
+	if err := RevokePersonalAccessToken(alice, token.ID); err != nil {
+		t.Fatalf("RevokePersonalAccessToken: %v", err)
+	}
+	if _, _, err := AuthenticatePersonalAccessToken(raw, ""); !errors.Is(err, ErrPersonalAccessTokenInvalid) {
+		t.Errorf("revoked token: err = %v, want ErrPersonalAccessTokenInvalid", err)
+	}
+	if err := RevokePersonalAccessToken(alice, token.ID); !errors.Is(err, ErrPersonalAccessTokenNotFound) {
+		t.Errorf("revoking twice: err = %v, want ErrPersonalAccessTokenNotFound", err)
+	}
+	if tokens, err := ListPersonalAccessTokens(alice); err != nil || len(tokens) != 0 {
+		t.Errorf("ListPersonalAccessTokens = %d tokens, %v, want none", len(tokens), err)
+	}
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrRoleExists         = errors.New("role already exists")
+	ErrRoleBuiltin        = errors.New("built-in role cannot be changed")
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrPermissionExists   = errors.New("permission already exists")
+	ErrPermissionBuiltin  = errors.New("built-in permission cannot be deleted")
+	ErrInvalidRBACName    = errors.New("invalid role or permission name")
+	ErrLastAdmin          = errors.New("the last admin cannot lose the admin role")
+)
+
+// LoadUserRoles loads the user's roles and their permissions into
+// user.Roles.
+func LoadUserRoles(user *models.User) error {
+	roles := []models.Role{}
+	err := database.DB.Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", user.ID).
+		Order("roles.name").
+		Find(&roles).Error
+	if err != nil {
+		return err
+	}
+	user.Roles = roles
+	return nil
+}
+
+// ensureUserRoles loads the user's roles unless they already are.
+func ensureUserRoles(user *models.User) error {
+	if user.Roles != nil {
+		return nil
+	}
+	return LoadUserRoles(user)
+}
+
+func ListPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := database.DB.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// CreatePermission adds a permission that roles may grant. This service
+// only checks the built-in permissions; others are meant for the
+// applications that read the permissions from our tokens.
+func CreatePermission(name string, description string, actorID string, ip string) (*models.Permission, error) {
+	if !models.ValidRBACName(name) {
+		return nil, ErrInvalidRBACName
+	}
+	permission := &models.Permission{Name: name, Description: description}
+	result := database.DB.Where("name = ?", name).FirstOrCreate(permission)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrPermissionExists
+	}
+
+	err := RecordAudit(models.AuditPermissionCreate, actorID, "", ip, map[string]interface{}{
+		"permission": permission.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return permission, nil
+}
+
+// DeletePermission removes a permission from every role that grants it and
+// records the change in the audit log. Tokens of the affected users are
+// revoked, so that they pick up the change when they refresh.
+func DeletePermission(name string, actorID string, ip string) error {
+	permission := new(models.Permission)
+	err := database.DB.Where("name = ?", name).First(permission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPermissionNotFound
+	} else if err != nil {
+		return err
+	}
+	if permission.IsBuiltin() {
+		return ErrPermissionBuiltin
+	}
+
+	affected, err := usersWithPermission(permission.ID)
+	if err != nil {
+		return err
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM role_permissions WHERE permission_id = ?", permission.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(permission).Error
+	})
+	if err != nil {
+		return err
+	}
+	if err := revokeUsers(affected); err != nil {
+		return err
+	}
+	return RecordAudit(models.AuditPermissionDelete, actorID, "", ip, map[string]interface{}{
+		"permission": permission.Name,
+	})
+}
+
+func ListRoles() ([]models.Role, error) {
+	var roles []models.Role
+	err := database.DB.Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func FindRole(name string) (*models.Role, error) {
+	role := new(models.Role)
+	err := database.DB.Preload("Permissions").Where("name = ?", name).First(role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRoleNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// CreateRole adds a role granting the named permissions, which must exist,
+// and records it in the audit log.
+func CreateRole(name string, description string, permissionNames []string, actorID string, ip string) (*models.Role, error) {
+	if !models.ValidRBACName(name) {
+		return nil, ErrInvalidRBACName
+	}
+	permissions, err := findPermissions(permissionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{Name: name, Description: description, Permissions: permissions}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrRoleExists
+		}
+		return tx.Create(role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = RecordAudit(models.AuditRoleCreate, actorID, "", ip, map[string]interface{}{
+		"role":        role.Name,
+		"permissions": role.PermissionNames(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// UpdateRole replaces the role's description and permissions and records
+// the change in the audit log. Tokens of the role's users are revoked, so
+// that they pick up the change when they refresh.
+func UpdateRole(role *models.Role, description string, permissionNames []string, actorID string, ip string) error {
+	if role.IsBuiltin() {
+		return ErrRoleBuiltin
+	}
+	permissions, err := findPermissions(permissionNames)
+	if err != nil {
+		return err
+	}
+
+	previous := role.PermissionNames()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Update("description", description).Error; err != nil {
+			return err
+		}
+		return tx.Model(role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return err
+	}
+	role.Description = description
+	role.Permissions = permissions
+
+	affected, err := usersWithRole(role.ID)
+	if err != nil {
+		return err
+	}
+	if err := revokeUsers(affected); err != nil {
+		return err
+	}
+	return RecordAudit(models.AuditRoleUpdate, actorID, "", ip, map[string]interface{}{
+		"role":        role.Name,
+		"previous":    previous,
+		"permissions": role.PermissionNames(),
+	})
+}
+
+// DeleteRole removes the role from its users, deletes it and records it in
+// the audit log. Tokens of the role's users are revoked.
+func DeleteRole(role *models.Role, actorID string, ip string) error {
+	if role.IsBuiltin() {
+		return ErrRoleBuiltin
+	}
+
+	affected, err := usersWithRole(role.ID)
+	if err != nil {
+		return err
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_roles WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		return err
+	}
+	if err := revokeUsers(affected); err != nil {
+		return err
+	}
+	return RecordAudit(models.AuditRoleDelete, actorID, "", ip, map[string]interface{}{
+		"role": role.Name,
+	})
+}
+
+// SetUserRoles replaces the user's roles with the named ones and records the
+// change in the audit log. The user's access tokens are revoked, so that
+// they pick up the change when they refresh. The last user with the admin
+// role cannot lose it.
+func SetUserRoles(user *models.User, roleNames []string, actorID string, ip string) error {
+	return setUserRoles(user, roleNames, actorID, ip, Revocations.RevokeUser)
+}
+
+// setUserRoles is SetUserRoles revoking the user's tokens with revoke.
+func setUserRoles(user *models.User, roleNames []string, actorID string, ip string, revoke func(userID string) error) error {
+	roles, err := findRoles(roleNames)
+	if err != nil {
+		return err
+	}
+	if err := ensureUserRoles(user); err != nil {
+		return err
+	}
+	previous := user.RoleNames()
+
+	keepsAdmin := slices.ContainsFunc(roles, func(role models.Role) bool { return role.IsBuiltin() })
+	if slices.Contains(previous, models.RoleAdmin) && !keepsAdmin {
+		var admins int64
+		err := database.DB.Table("user_roles").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("roles.name = ? AND user_roles.user_id <> ?", models.RoleAdmin, user.ID).
+			Count(&admins).Error
+		if err != nil {
+			return err
+		}
+		if admins == 0 {
+			return ErrLastAdmin
+		}
+	}
+
+	if err := database.DB.Model(user).Association("Roles").Replace(roles); err != nil {
+		return err
+	}
+	if err := LoadUserRoles(user); err != nil {
+		return err
+	}
+
+	if err := revoke(user.UID); err != nil {
+		return err
+	}
+	return RecordAudit(models.AuditUserRolesUpdate, actorID, user.UID, ip, map[string]interface{}{
+		"previous": previous,
+		"roles":    user.RoleNames(),
+	})
+}
+
+// syncManagedRoles makes the user hold exactly those of the managed roles
+// that are granted, leaving roles outside managed alone. Identity providers
+// whose groups map to roles use it on every login. Roles that do not exist
+// are logged and ignored. Changes go through the same checks as SetUserRoles,
+// except that the last admin keeps the admin role with a warning instead of
+// failing the login, and the tokens revoked are those issued before the
+// login.
+func syncManagedRoles(user *models.User, managed []string, granted []string, ip string) error {
+	if len(managed) == 0 {
+		return nil
+	}
+	if err := ensureUserRoles(user); err != nil {
+		return err
+	}
+
+	roles := user.RoleNames()
+	changed := false
+	for _, name := range managed {
+		held := slices.Contains(roles, name)
+		if held == slices.Contains(granted, name) {
+			continue
+		}
+		if _, err := FindRole(name); errors.Is(err, ErrRoleNotFound) {
+			log.Printf("Role %q mapped from identity provider groups does not exist", name)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if held {
+			roles = slices.DeleteFunc(roles, func(role string) bool { return role == name })
+		} else {
+			roles = append(roles, name)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	err := setUserRoles(user, roles, "", ip, Revocations.RevokeEarlierUserTokens)
+	if !errors.Is(err, ErrLastAdmin) {
+		return err
+	}
+	log.Printf("User %s keeps the admin role the identity provider no longer grants, as they are the last admin", user.UID)
+	roles = append(roles, models.RoleAdmin)
+	slices.Sort(roles)
+	if slices.Equal(roles, user.RoleNames()) {
+		return nil
+	}
+	return setUserRoles(user, roles, "", ip, Revocations.RevokeEarlierUserTokens)
+}
+
+func findPermissions(names []string) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	if err := database.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(permissions, func(p models.Permission) bool { return p.Name == name }) {
+			return nil, ErrPermissionNotFound
+		}
+	}
+	return permissions, nil
+}
+
+func findRoles(names []string) ([]models.Role, error) {
+	roles := []models.Role{}
+	if len(names) == 0 {
+		return roles, nil
+	}
+	if err := database.DB.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(roles, func(role models.Role) bool { return role.Name == name }) {
+			return nil, ErrRoleNotFound
+		}
+	}
+	return roles, nil
+}
+
+func usersWithRole(roleID uint) ([]string, error) {
+	var uids []string
+	err := database.DB.Model(&models.User{}).
+		Joins("JOIN user_roles ON user_roles.user_id = users.id").
+		Where("user_roles.role_id = ?", roleID).
+		Distinct().Pluck("users.uid", &uids).Error
+	return uids, err
+}
+
+func usersWithPermission(permissionID uint) ([]string, error) {
+	var uids []string
+	err := database.DB.Model(&models.User{}).
+		Joins("JOIN user_roles ON user_roles.user_id = users.id").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Where("role_permissions.permission_id = ?", permissionID).
+		Distinct().Pluck("users.uid", &uids).Error
+	return uids, err
+}
+
+// revokeUsers revokes the access tokens of the users with the given UIDs.
+func revokeUsers(uids []string) error {
+	for _, uid := range uids {
+		if err := Revocations.RevokeUser(uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
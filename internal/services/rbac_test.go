@@ -0,0 +1,208 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+// registerTestUser creates an active user holding the named roles.
+func registerTestUser(t *testing.T, username string, roles ...string) *models.User {
+	t.Helper()
+	user, err := RegisterUser(NewUser{
+		Username:      username,
+		Email:         username + "@example.com",
+		Password:      "correct horse battery staple",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) > 0 {
+		if err := SetUserRoles(user, roles, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return user
+}
+
+// useTestRevocations gives the test a revocation store of its own.
+func useTestRevocations(t *testing.T) {
+	previous := Revocations
+	Revocations = NewRevocationStore()
+	t.Cleanup(func() { Revocations = previous })
+}
+
+func TestSyncManagedRolesRevokesEarlierTokens(t *testing.T) {
+	setupTestDB(t)
+	if _, err := CreateRole("editor", "", nil, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	user := registerTestUser(t, "alice", "editor")
+	useTestRevocations(t)
+	before := time.Now().Add(-time.Second)
+
+	if err := syncManagedRoles(user, []string{"editor"}, nil, "127.0.0.1"); err != nil {
+		t.Fatalf("syncManagedRoles: %v", err)
+	}
+	if len(user.RoleNames()) != 0 {
+		t.Errorf("roles = %v, want none", user.RoleNames())
+	}
+	if !Revocations.IsRevoked(accessClaims(user.UID, "jti-1", "", before)) {
+		t.Error("token issued before the login still carries the removed role")
+	}
+	if Revocations.IsRevoked(accessClaims(user.UID, "jti-2", "", time.Now())) {
+		t.Error("token issued for the login is revoked")
+	}
+}
+
+func TestSyncManagedRolesKeepsLastAdmin(t *testing.T) {
+	setupTestDB(t)
+	if _, err := CreateRole("editor", "", nil, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	admin := registerTestUser(t, "alice", models.RoleAdmin, "editor")
+	if err := SetUserRoles(admin, nil, "", ""); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("SetUserRoles = %v, want ErrLastAdmin", err)
+	}
+
+	if err := syncManagedRoles(admin, []string{models.RoleAdmin, "editor"}, nil, "127.0.0.1"); err != nil {
+		t.Fatalf("syncManagedRoles: %v", err)
+	}
+	if !slices.Equal(admin.RoleNames(), []string{models.RoleAdmin}) {
+		t.Errorf("roles = %v, want only the admin role", admin.RoleNames())
+	}
+	if err := syncManagedRoles(admin, []string{models.RoleAdmin}, nil, "127.0.0.1"); err != nil {
+		t.Errorf("syncManagedRoles removing only the admin role: %v", err)
+	}
+
+	// Once there is another admin, the directory can take the role away.
+	registerTestUser(t, "bob", models.RoleAdmin)
+	if err := syncManagedRoles(admin, []string{models.RoleAdmin}, nil, "127.0.0.1"); err != nil {
+		t.Fatalf("syncManagedRoles: %v", err)
+	}
+	if len(admin.RoleNames()) != 0 {
+		t.Errorf("roles = %v, want none", admin.RoleNames())
+	}
+}
+
+func TestSetUserRolesGuardsLastAdmin(t *testing.T) {
+	setupTestDB(t)
+	alice := registerTestUser(t, "alice", models.RoleAdmin)
+
+	if err := SetUserRoles(alice, nil, "", ""); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("SetUserRoles = %v, want ErrLastAdmin", err)
+	}
+	if err := LoadUserRoles(alice); err != nil {
+		t.Fatal(err)
+	}
+	if !alice.IsAdmin() {
+		t.Errorf("roles = %v, want the admin role kept", alice.RoleNames())
+	}
+
+	registerTestUser(t, "bob", models.RoleAdmin)
+	if err := SetUserRoles(alice, nil, "", ""); err != nil {
+		t.Fatalf("SetUserRoles with another admin: %v", err)
+	}
+	if alice.IsAdmin() {
+		t.Errorf("roles = %v, want none", alice.RoleNames())
+	}
+}
+
+func TestSetUserRolesRevokesTokens(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	useTestRevocations(t)
+
+	if err := SetUserRoles(user, []string{"missing"}, "", ""); !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("SetUserRoles with an unknown role = %v, want ErrRoleNotFound", err)
+	}
+	if Revocations.IsRevoked(accessClaims(user.UID, "jti-1", "", time.Now().Add(-time.Minute))) {
+		t.Fatal("failed role change revoked the user's tokens")
+	}
+
+	if err := SetUserRoles(user, []string{models.RoleAdmin}, "", ""); err != nil {
+		t.Fatalf("SetUserRoles: %v", err)
+	}
+	if !Revocations.IsRevoked(accessClaims(user.UID, "jti-1", "", time.Now().Add(-time.Minute))) {
+		t.Error("token issued before the role change is not revoked")
+	}
+}
+
+func TestCreateRole(t *testing.T) {
+	setupTestDB(t)
+
+	role, err := CreateRole("hiring-manager", "Hires people", []string{models.PermissionUsersRead}, "", "")
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if !slices.Equal(role.PermissionNames(), []string{models.PermissionUsersRead}) {
+		t.Errorf("permissions = %v, want %s", role.PermissionNames(), models.PermissionUsersRead)
+	}
+	if _, err := CreateRole("hiring-manager", "", nil, "", ""); !errors.Is(err, ErrRoleExists) {
+		t.Errorf("duplicate role: err = %v, want ErrRoleExists", err)
+	}
+	if _, err := CreateRole("Hiring Manager", "", nil, "", ""); !errors.Is(err, ErrInvalidRBACName) {
+		t.Errorf("invalid name: err = %v, want ErrInvalidRBACName", err)
+	}
+	if _, err := CreateRole("recruiter", "", []string{"candidates:read"}, "", ""); !errors.Is(err, ErrPermissionNotFound) {
+		t.Errorf("unknown permission: err = %v, want ErrPermissionNotFound", err)
+	}
+}
+
+func TestUpdateAndDeleteRole(t *testing.T) {
+	setupTestDB(t)
+	if _, err := CreateRole("editor", "", nil, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	user := registerTestUser(t, "alice", "editor")
+	useTestRevocations(t)
+	issued := time.Now().Add(-time.Minute)
+
+	admin, err := FindRole(models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateRole(admin, "", nil, "", ""); !errors.Is(err, ErrRoleBuiltin) {
+		t.Errorf("UpdateRole(admin) = %v, want ErrRoleBuiltin", err)
+	}
+	if err := DeleteRole(admin, "", ""); !errors.Is(err, ErrRoleBuiltin) {
+		t.Errorf("DeleteRole(admin) = %v, want ErrRoleBuiltin", err)
+	}
+
+	role, err := FindRole("editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateRole(role, "Edits things", []string{models.PermissionUsersRead}, "", ""); err != nil {
+		t.Fatalf("UpdateRole: %v", err)
+	}
+	if err := LoadUserRoles(user); err != nil {
+		t.Fatal(err)
+	}
+	if !user.HasPermission(models.PermissionUsersRead) {
+		t.Errorf("permissions = %v, want %s", user.PermissionNames(), models.PermissionUsersRead)
+	}
+	if !Revocations.IsRevoked(accessClaims(user.UID, "jti-1", "", issued)) {
+		t.Error("UpdateRole did not revoke the tokens of the role's users")
+	}
+
+	useTestRevocations(t)
+	if err := DeleteRole(role, "", ""); err != nil {
+		t.Fatalf("DeleteRole: %v", err)
+	}
+	if err := LoadUserRoles(user); err != nil {
+		t.Fatal(err)
+	}
+	if len(user.RoleNames()) != 0 {
+		t.Errorf("roles = %v, want none", user.RoleNames())
+	}
+	if !Revocations.IsRevoked(accessClaims(user.UID, "jti-1", "", issued)) {
+		t.Error("DeleteRole did not revoke the tokens of the role's users")
+	}
+	if _, err := FindRole("editor"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("FindRole after DeleteRole = %v, want ErrRoleNotFound", err)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const refreshTokenBytes = 32
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid")
+	ErrRefreshTokenExpired = errors.New("refresh token has expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// IssueClientRefreshToken starts a new token family for a user who granted
+// scope to an OAuth client.
+func IssueClientRefreshToken(userID uint, clientID string, scope string, ip string) (string, *models.RefreshToken, error) {
+	return issueRefreshToken(database.DB, &models.RefreshToken{
+		UserID:      userID,
+		ClientID:    clientID,
+		Scope:       scope,
+		CreatedByIP: ip,
+	})
+}
+
+func issueRefreshToken(tx *gorm.DB, token *models.RefreshToken) (string, *models.RefreshToken, error) {
+	raw, err := utils.GenerateOpaqueToken(refreshTokenBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	if token.FamilyID == "" {
+		token.FamilyID = uuid.NewString()
+	}
+
+	token.TokenHash = utils.HashToken(raw)
+	token.ExpiresAt = time.Now().Add(config.GetRefreshTokenTTL())
+	if err := tx.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, token, nil
+}
+
+// RotateRefreshToken consumes the presented refresh token and returns its
+// replacement. The token must have been issued to clientID, which is empty for
+// first-party logins. Presenting a token that was already rotated revokes the
+// whole family, since either the legitimate client or an attacker holds a
+// copy.
+func RotateRefreshToken(raw string, clientID string, ip string) (string, *models.RefreshToken, error) {
+	var (
+		newRaw   string
+		newToken *models.RefreshToken
+		reused   string
+	)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		current := new(models.RefreshToken)
+		result := tx.Where("token_hash = ?", utils.HashToken(raw)).First(current)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ErrRefreshTokenInvalid
+		} else if result.Error != nil {
+			return result.Error
+		}
+		if current.ClientID != clientID {
+			return ErrRefreshTokenInvalid
+		}
+
+		if current.IsRevoked() {
+			reused = current.FamilyID
+			return ErrRefreshTokenReused
+		}
+		if current.IsExpired() {
+			return ErrRefreshTokenExpired
+		}
+
+		now := time.Now()
+		update := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Update("revoked_at", now)
+		if update.Error != nil {
+			return update.Error
+		}
+		if update.RowsAffected == 0 {
+			// Another request rotated this token concurrently.
+			reused = current.FamilyID
+			return ErrRefreshTokenReused
+		}
+
+		var err error
+		newRaw, newToken, err = issueRefreshToken(tx, &models.RefreshToken{
+			UserID:      current.UserID,
+			FamilyID:    current.FamilyID,
+			ClientID:    current.ClientID,
+			Scope:       current.Scope,
+			CreatedByIP: ip,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Model(current).Update("replaced_by_id", newToken.ID).Error
+	})
+
+	if reused != "" {
+		if err := RevokeRefreshTokenFamily(reused); err != nil {
+			return "", nil, err
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return newRaw, newToken, nil
+}
+
+// RevokeRefreshTokenFamily revokes every outstanding token of a family and
+// ends the session it belongs to.
+func RevokeRefreshTokenFamily(familyID string) error {
+	err := database.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return endSessions("session_id = ?", familyID)
+}
+
+// RevokeUserRefreshTokens revokes every outstanding refresh token of a user.
+func RevokeUserRefreshTokens(userID uint) error {
+	return database.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
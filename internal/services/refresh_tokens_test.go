@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	raw, issued, err := IssueClientRefreshToken(user.ID, "", "", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	successor, rotated, err := RotateRefreshToken(raw, "", "127.0.0.2")
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	if successor == raw || rotated.FamilyID != issued.FamilyID || rotated.UserID != user.ID {
+		t.Errorf("rotated token = %+v, want a new token of the same family", rotated)
+	}
+	if _, _, err := RotateRefreshToken(successor, "", "127.0.0.2"); err != nil {
+		t.Errorf("RotateRefreshToken of the successor: %v", err)
+	}
+}
+
+func TestRotateRefreshTokenDetectsReuse(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	raw, _, err := IssueClientRefreshToken(user.ID, "", "", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	successor, _, err := RotateRefreshToken(raw, "", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := RotateRefreshToken(raw, "", "127.0.0.1"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RotateRefreshToken of a rotated token = %v, want ErrRefreshTokenReused", err)
+	}
+	// Whoever held the successor loses it too.
+	if _, _, err := RotateRefreshToken(successor, "", "127.0.0.1"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("RotateRefreshToken of the successor = %v, want ErrRefreshTokenReused", err)
+	}
+}
+
+func TestRotateRefreshTokenRejectsInvalidTokens(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	raw, issued, err := IssueClientRefreshToken(user.ID, "app", "offline_access", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := RotateRefreshToken("unknown", "app", "127.0.0.1"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("RotateRefreshToken of an unknown token = %v, want ErrRefreshTokenInvalid", err)
+	}
+	if _, _, err := RotateRefreshToken(raw, "", "127.0.0.1"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("RotateRefreshToken for another client = %v, want ErrRefreshTokenInvalid", err)
+	}
+
+	if err := database.DB.Model(issued).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RotateRefreshToken(raw, "app", "127.0.0.1"); !errors.Is(err, ErrRefreshTokenExpired) {
+		t.Errorf("RotateRefreshToken of an expired token = %v, want ErrRefreshTokenExpired", err)
+	}
+}
+
+func TestRevokeRefreshTokenFamily(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	raw, issued, err := IssueClientRefreshToken(user.ID, "", "", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := IssueClientRefreshToken(user.ID, "", "", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RevokeRefreshTokenFamily(issued.FamilyID); err != nil {
+		t.Fatal(err)
+	}
+	var token models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(raw)).First(&token).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !token.IsRevoked() {
+		t.Error("token of the revoked family is not revoked")
+	}
+	if _, _, err := RotateRefreshToken(other, "", "127.0.0.1"); err != nil {
+		t.Errorf("RotateRefreshToken of another family: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"log"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"sync"
+	"time"
+)
+
+// RevocationStore keeps the access token denylist in memory and periodically
+// reloads it from the database so that revocations made by other replicas are
+// picked up.
+type RevocationStore struct {
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	sessions map[string]time.Time
+	users    map[string]userRevocation
+}
+
+type userRevocation struct {
+	revokedAt time.Time
+	expiresAt time.Time
+}
+
+var Revocations = NewRevocationStore()
+
+func NewRevocationStore() *RevocationStore {
+	return &RevocationStore{
+		tokens:   make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		users:    make(map[string]userRevocation),
+	}
+}
+
+// InitRevocationStore loads the denylist and starts the background sync.
+func InitRevocationStore() error {
+	if err := Revocations.Load(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.GetRevocationSyncInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := Revocations.Prune(); err != nil {
+				log.Printf("Error pruning revoked tokens: %v", err)
+			}
+			if err := Revocations.Load(); err != nil {
+				log.Printf("Error loading revoked tokens: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Load merges the unexpired database entries into the in-memory denylist and
+// drops the expired ones.
+func (s *RevocationStore) Load() error {
+	now := time.Now()
+	var entries []models.RevokedToken
+	if err := database.DB.Where("expires_at > ?", now).Find(&entries).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for jti, expiresAt := range s.tokens {
+		if !expiresAt.After(now) {
+			delete(s.tokens, jti)
+		}
+	}
+	for sessionID, expiresAt := range s.sessions {
+		if !expiresAt.After(now) {
+			delete(s.sessions, sessionID)
+		}
+	}
+	for userID, revocation := range s.users {
+		if !revocation.expiresAt.After(now) {
+			delete(s.users, userID)
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.IsUserWide() {
+			s.addUser(entry.UserID, entry.CreatedAt, entry.ExpiresAt)
+		} else if entry.IsSessionWide() {
+			s.sessions[entry.SessionID] = entry.ExpiresAt
+		} else {
+			s.tokens[entry.JTI] = entry.ExpiresAt
+		}
+	}
+	return nil
+}
+
+func (s *RevocationStore) addUser(userID string, revokedAt time.Time, expiresAt time.Time) {
+	current, ok := s.users[userID]
+	if !ok || revokedAt.After(current.revokedAt) {
+		current.revokedAt = revokedAt
+	}
+	if expiresAt.After(current.expiresAt) {
+		current.expiresAt = expiresAt
+	}
+	s.users[userID] = current
+}
+
+// Prune deletes entries whose tokens have all expired.
+func (s *RevocationStore) Prune() error {
+	return database.DB.Unscoped().Where("expires_at <= ?", time.Now()).Delete(&models.RevokedToken{}).Error
+}
+
+// RevokeToken denylists a single token until it expires.
+func (s *RevocationStore) RevokeToken(jti string, userID string, expiresAt time.Time) error {
+	entry := &models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+	if err := database.DB.Create(entry).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = expiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeSession denylists every access token issued for a login session.
+func (s *RevocationStore) RevokeSession(sessionID string, userID string) error {
+	entry := &models.RevokedToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		ExpiresAt: time.Now().Add(config.GetMaxAccessTokenTTL()),
+	}
+	if err := database.DB.Create(entry).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.sessions[sessionID] = entry.ExpiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeUser denylists every token issued to the user so far, until the
+// longest-lived of them has expired. userID may also be the client ID of a
+// service principal.
+func (s *RevocationStore) RevokeUser(userID string) error {
+	return s.revokeUser(userID, time.Now())
+}
+
+// RevokeEarlierUserTokens is RevokeUser for a login in progress. Issue times
+// only have second precision, so it spares tokens issued within the current
+// second, including the one about to be issued for the login.
+func (s *RevocationStore) RevokeEarlierUserTokens(userID string) error {
+	return s.revokeUser(userID, time.Now().Truncate(time.Second).Add(-time.Nanosecond))
+}
+
+func (s *RevocationStore) revokeUser(userID string, revokedAt time.Time) error {
+	entry := &models.RevokedToken{
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(config.GetMaxAccessTokenTTL()),
+	}
+	entry.CreatedAt = revokedAt
+	if err := database.DB.Create(entry).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.addUser(userID, entry.CreatedAt, entry.ExpiresAt)
+	s.mu.Unlock()
+	return nil
+}
+
+// IsRevoked reports whether the token described by claims has been revoked.
+// Impersonation tokens are also revoked with the acting admin's tokens.
+// Issue times only have second precision, so a token issued within the same
+// second as a user-wide revocation is treated as revoked.
+func (s *RevocationStore) IsRevoked(claims *models.JwtCustomClaims) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if claims.ID != "" {
+		if _, ok := s.tokens[claims.ID]; ok {
+			return true
+		}
+	}
+	if claims.SessionID != "" {
+		if _, ok := s.sessions[claims.SessionID]; ok {
+			return true
+		}
+	}
+
+	if s.revokedForUser(claims.PrincipalID(), claims) {
+		return true
+	}
+	return claims.IsImpersonation() && s.revokedForUser(claims.Actor.Subject, claims)
+}
+
+// revokedForUser reports whether the token was issued before the last
+// user-wide revocation of userID. The caller holds s.mu.
+func (s *RevocationStore) revokedForUser(userID string, claims *models.JwtCustomClaims) bool {
+	revocation, ok := s.users[userID]
+	if !ok {
+		return false
+	}
+	if claims.IssuedAt == nil {
+		return true
+	}
+	return !claims.IssuedAt.Time.After(revocation.revokedAt)
+}
+
+// RevokeAllUserTokens revokes the user's access tokens, refresh tokens,
+// sessions and personal access tokens.
+func RevokeAllUserTokens(user *models.User) error {
+	if err := Revocations.RevokeUser(user.UID); err != nil {
+		return err
+	}
+	if err := RevokeUserRefreshTokens(user.ID); err != nil {
+		return err
+	}
+	if err := revokeUserPersonalAccessTokens(user.ID); err != nil {
+		return err
+	}
+	return endSessions("user_id = ?", user.ID)
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func accessClaims(userID, jti, sessionID string, issuedAt time.Time) *models.JwtCustomClaims {
+	return &models.JwtCustomClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       jti,
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	setupTestDB(t)
+	store := NewRevocationStore()
+
+	if err := store.RevokeToken("jti-1", "user-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsRevoked(accessClaims("user-1", "jti-1", "", time.Now())) {
+		t.Error("revoked token is not revoked")
+	}
+	if store.IsRevoked(accessClaims("user-1", "jti-2", "", time.Now())) {
+		t.Error("another token of the user is revoked")
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	setupTestDB(t)
+	store := NewRevocationStore()
+
+	if err := store.RevokeSession("session-1", "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsRevoked(accessClaims("user-1", "jti-1", "session-1", time.Now().Add(time.Minute))) {
+		t.Error("token of the revoked session is not revoked")
+	}
+	if store.IsRevoked(accessClaims("user-1", "jti-2", "session-2", time.Now())) {
+		t.Error("token of another session is revoked")
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	setupTestDB(t)
+	store := NewRevocationStore()
+	before := time.Now().Add(-time.Minute)
+
+	if err := store.RevokeUser("user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsRevoked(accessClaims("user-1", "jti-1", "", before)) {
+		t.Error("token issued before the revocation is not revoked")
+	}
+	if store.IsRevoked(accessClaims("user-1", "jti-2", "", time.Now().Add(time.Minute))) {
+		t.Error("token issued after the revocation is revoked")
+	}
+	if store.IsRevoked(accessClaims("user-2", "jti-3", "", before)) {
+		t.Error("token of another user is revoked")
+	}
+
+	impersonation := accessClaims("user-2", "jti-4", "", before)
+	impersonation.Actor = &models.Actor{Subject: "user-1"}
+	if !store.IsRevoked(impersonation) {
+		t.Error("impersonation token of the revoked admin is not revoked")
+	}
+}
+
+// Tokens issued before ACCESS_TOKEN_TTL was introduced lived for 24 hours,
+// and revocations must outlast them however short the current lifetime is.
+func TestRevocationsOutlastLegacyTokens(t *testing.T) {
+	setupTestDB(t)
+	setConfig(t, map[string]string{"ACCESS_TOKEN_TTL": "5m"})
+	store := NewRevocationStore()
+
+	if err := store.RevokeUser("user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeSession("session-1", "user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []models.RevokedToken
+	if err := database.DB.Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d revocations, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if remaining := time.Until(entry.ExpiresAt); remaining < 23*time.Hour {
+			t.Errorf("revocation expires in %v, want 24h", remaining)
+		}
+	}
+}
+
+func TestRevocationStoreLoad(t *testing.T) {
+	setupTestDB(t)
+	if err := NewRevocationStore().RevokeToken("jti-1", "user-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewRevocationStore().RevokeToken("jti-2", "user-1", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewRevocationStore().RevokeUser("user-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another replica picks the revocations up from the database.
+	store := NewRevocationStore()
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsRevoked(accessClaims("user-1", "jti-1", "", time.Now())) {
+		t.Error("loaded token revocation is not applied")
+	}
+	if store.IsRevoked(accessClaims("user-1", "jti-2", "", time.Now())) {
+		t.Error("expired token revocation is applied")
+	}
+	if !store.IsRevoked(accessClaims("user-2", "jti-3", "", time.Now().Add(-time.Minute))) {
+		t.Error("loaded user revocation is not applied")
+	}
+}
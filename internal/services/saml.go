@@ -0,0 +1,313 @@
+package services
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"slices"
+	"time"
+
+	"github.com/crewjam/saml"
+	"gorm.io/gorm/clause"
+)
+
+// samlProvider is the FederatedIdentity provider name of SAML logins.
+const samlProvider = "saml"
+
+var (
+	ErrSAMLDisabled         = errors.New("SAML is not configured")
+	ErrSAMLAssertionInvalid = errors.New("SAML assertion is invalid")
+	ErrSAMLEmailMissing     = errors.New("SAML assertion has no email address")
+)
+
+var (
+	samlSP     *saml.ServiceProvider
+	samlConfig config.SAMLConfig
+)
+
+// InitSAML sets up the service provider from the configured key pair and IdP
+// metadata. It does nothing when SAML is not configured.
+func InitSAML() error {
+	if !config.IsSAMLEnabled() {
+		return nil
+	}
+	cfg := config.GetSAMLConfig()
+
+	keyPair, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load SAML key pair: %w", err)
+	}
+	key, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("SAML key must be an RSA key")
+	}
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse SAML certificate: %w", err)
+	}
+
+	idpMetadata, err := loadIDPMetadata(cfg)
+	if err != nil {
+		return err
+	}
+
+	metadataURL, err := url.Parse(config.GetSAMLMetadataURL())
+	if err != nil {
+		return err
+	}
+	acsURL, err := url.Parse(config.GetSAMLACSURL())
+	if err != nil {
+		return err
+	}
+
+	samlSP = &saml.ServiceProvider{
+		EntityID:          cfg.EntityID,
+		Key:               key,
+		Certificate:       certificate,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       idpMetadata,
+		AllowIDPInitiated: cfg.AllowIDPInitiated,
+		// Let the IdP choose its configured, usually persistent, NameID format.
+		// Identities are linked by NameID, so a transient one would not do.
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+	}
+	samlConfig = cfg
+	return nil
+}
+
+func loadIDPMetadata(cfg config.SAMLConfig) (*saml.EntityDescriptor, error) {
+	var data []byte
+	if cfg.IDPMetadataFile != "" {
+		var err error
+		if data, err = os.ReadFile(cfg.IDPMetadataFile); err != nil {
+			return nil, fmt.Errorf("failed to read IdP metadata: %w", err)
+		}
+	} else {
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Get(cfg.IDPMetadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch IdP metadata: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch IdP metadata: %s", resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("failed to fetch IdP metadata: %w", err)
+		}
+	}
+
+	metadata := new(saml.EntityDescriptor)
+	if err := xml.Unmarshal(data, metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse IdP metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// SAMLMetadata returns the service provider metadata to register with the
+// IdP.
+func SAMLMetadata() ([]byte, error) {
+	if samlSP == nil {
+		return nil, ErrSAMLDisabled
+	}
+	return xml.MarshalIndent(samlSP.Metadata(), "", "  ")
+}
+
+// BeginSAMLLogin returns the IdP URL to redirect the browser to and the ID of
+// the authentication request, which the response must answer.
+func BeginSAMLLogin() (string, string, error) {
+	if samlSP == nil {
+		return "", "", ErrSAMLDisabled
+	}
+
+	request, err := samlSP.MakeAuthenticationRequest(
+		samlSP.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", "", err
+	}
+	redirectURL, err := request.Redirect("", samlSP)
+	if err != nil {
+		return "", "", err
+	}
+	err = database.DB.Create(&models.SAMLRequest{
+		RequestID: request.ID,
+		ExpiresAt: time.Now().Add(FederatedLoginStateTTL),
+	}).Error
+	if err != nil {
+		return "", "", err
+	}
+	return redirectURL.String(), request.ID, nil
+}
+
+// FinishSAMLLogin validates the signed assertion posted to the ACS endpoint
+// and returns the user it describes, linking or registering them like other
+// federated logins. The assertion's attributes are mapped onto the user.
+func FinishSAMLLogin(r *http.Request, requestIDs []string, ip string) (*models.User, error) {
+	if samlSP == nil {
+		return nil, ErrSAMLDisabled
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSAMLAssertionInvalid, err)
+	}
+	assertion, err := samlSP.ParseResponse(r, requestIDs)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			return nil, fmt.Errorf("%w: %v", ErrSAMLAssertionInvalid, invalid.PrivateErr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrSAMLAssertionInvalid, err)
+	}
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrSAMLAssertionInvalid)
+	}
+
+	// The library checks that the assertion answers one of the requestIDs,
+	// which come from the browser. Each request must also have been issued
+	// by us and is only answered once, and each assertion is only accepted
+	// once.
+	requestID := samlRequestID(assertion)
+	if requestID != "" && slices.Contains(requestIDs, requestID) {
+		if err := consumeSAMLRequest(requestID); err != nil {
+			return nil, err
+		}
+	} else if !samlSP.AllowIDPInitiated {
+		return nil, fmt.Errorf("%w: assertion answers no request", ErrSAMLAssertionInvalid)
+	}
+	if err := recordSAMLAssertion(assertion); err != nil {
+		return nil, err
+	}
+
+	attributes := samlAttributes(assertion)
+	claims := federatedClaims{
+		Email:      firstValue(attributes[samlConfig.EmailAttribute]),
+		GivenName:  firstValue(attributes[samlConfig.FirstNameAttribute]),
+		FamilyName: firstValue(attributes[samlConfig.LastNameAttribute]),
+	}
+	if claims.Email == "" && assertion.Subject.NameID.Format == string(saml.EmailAddressNameIDFormat) {
+		claims.Email = assertion.Subject.NameID.Value
+	}
+	if claims.Email == "" {
+		return nil, ErrSAMLEmailMissing
+	}
+
+	// The IdP is trusted to have verified the addresses of its users.
+	user, err := resolveFederatedUser(samlProvider, assertion.Subject.NameID.Value, claims, true, ip)
+	if err != nil {
+		return nil, err
+	}
+	if err := updateFederatedProfile(user, claims); err != nil {
+		return nil, err
+	}
+	if err := syncManagedRoles(user, samlManagedRoles(), samlRoles(attributes[samlConfig.GroupsAttribute]), ip); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// samlRequestID returns the ID of the request the assertion answers, if any.
+func samlRequestID(assertion *saml.Assertion) string {
+	for _, confirmation := range assertion.Subject.SubjectConfirmations {
+		if confirmation.SubjectConfirmationData != nil && confirmation.SubjectConfirmationData.InResponseTo != "" {
+			return confirmation.SubjectConfirmationData.InResponseTo
+		}
+	}
+	return ""
+}
+
+// consumeSAMLRequest marks the request as answered. It fails unless the
+// request was issued by BeginSAMLLogin, has not expired and was not answered
+// before.
+func consumeSAMLRequest(requestID string) error {
+	now := time.Now()
+	update := database.DB.Model(&models.SAMLRequest{}).
+		Where("request_id = ? AND expires_at > ? AND used_at IS NULL", requestID, now).
+		Update("used_at", now)
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return fmt.Errorf("%w: request %s is unknown, expired or already answered", ErrSAMLAssertionInvalid, requestID)
+	}
+	return nil
+}
+
+// recordSAMLAssertion remembers the assertion until the library would reject
+// it as expired anyway, and fails if it was accepted before.
+func recordSAMLAssertion(assertion *saml.Assertion) error {
+	now := time.Now()
+	if err := database.DB.Where("expires_at < ?", now).Delete(&models.SAMLAssertion{}).Error; err != nil {
+		return err
+	}
+
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.SAMLAssertion{
+		AssertionID: assertion.ID,
+		ExpiresAt:   assertion.IssueInstant.Add(saml.MaxIssueDelay),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: assertion %s was already used", ErrSAMLAssertionInvalid, assertion.ID)
+	}
+	return nil
+}
+
+// samlManagedRoles returns the roles set from the user's groups on each
+// login, which are those named by the group mappings.
+func samlManagedRoles() []string {
+	var roles []string
+	for _, mapping := range samlConfig.GroupRoles {
+		roles = append(roles, mapping.Role)
+	}
+	slices.Sort(roles)
+	return slices.Compact(roles)
+}
+
+// samlRoles returns the roles given by the user's groups.
+func samlRoles(groups []string) []string {
+	var roles []string
+	for _, mapping := range samlConfig.GroupRoles {
+		if slices.Contains(groups, mapping.Group) {
+			roles = append(roles, mapping.Role)
+		}
+	}
+	return roles
+}
+
+// samlAttributes collects the assertion's attribute values by attribute name
+// and by friendly name.
+func samlAttributes(assertion *saml.Assertion) map[string][]string {
+	attributes := make(map[string][]string)
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			var values []string
+			for _, value := range attribute.Values {
+				values = append(values, value.Value)
+			}
+			attributes[attribute.Name] = append(attributes[attribute.Name], values...)
+			if attribute.FriendlyName != "" && attribute.FriendlyName != attribute.Name {
+				attributes[attribute.FriendlyName] = append(attributes[attribute.FriendlyName], values...)
+			}
+		}
+	}
+	return attributes
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"platform-service/internal/models"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+)
+
+// testSAMLIdP is an identity provider signing assertions with a self-signed
+// certificate. It answers the service provider's authentication requests
+// in-process instead of over HTTP.
+type testSAMLIdP struct {
+	t   *testing.T
+	idp *saml.IdentityProvider
+}
+
+func newSelfSignedCertificate(t *testing.T, commonName string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, certificate
+}
+
+func newTestSAMLIdP(t *testing.T) *testSAMLIdP {
+	t.Helper()
+	key, certificate := newSelfSignedCertificate(t, "idp.example.com")
+	metadataURL, _ := url.Parse("https://idp.example.com/metadata")
+	ssoURL, _ := url.Parse("https://idp.example.com/sso")
+	idp := &saml.IdentityProvider{
+		Key:         key,
+		Certificate: certificate,
+		MetadataURL: *metadataURL,
+		SSOURL:      *ssoURL,
+	}
+	return &testSAMLIdP{t: t, idp: idp}
+}
+
+// GetServiceProvider returns the metadata of the service provider under test
+// without its keys, so that assertions are signed but not encrypted and tests
+// can read them.
+func (i *testSAMLIdP) GetServiceProvider(r *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	if samlSP == nil || serviceProviderID != samlSP.EntityID {
+		return nil, os.ErrNotExist
+	}
+	metadata := samlSP.Metadata()
+	for i := range metadata.SPSSODescriptors {
+		metadata.SPSSODescriptors[i].KeyDescriptors = nil
+	}
+	return metadata, nil
+}
+
+// respond signs the user in for the authentication request behind
+// redirectURL and returns the SAMLResponse the browser would post to the
+// ACS endpoint.
+func (i *testSAMLIdP) respond(redirectURL string, session *saml.Session) string {
+	i.t.Helper()
+	req, err := saml.NewIdpAuthnRequest(i.idp, httptest.NewRequest(http.MethodGet, redirectURL, nil))
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	if err := req.Validate(); err != nil {
+		i.t.Fatalf("authentication request is invalid: %v", err)
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		i.t.Fatal(err)
+	}
+	form, err := req.PostBinding()
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return form.SAMLResponse
+}
+
+func setupTestSAML(t *testing.T) *testSAMLIdP {
+	t.Helper()
+	setupTestDB(t)
+	idp := newTestSAMLIdP(t)
+	idp.idp.ServiceProviderProvider = idp
+
+	dir := t.TempDir()
+	spKey, spCertificate := newSelfSignedCertificate(t, "sp.example.com")
+	writePEM(t, filepath.Join(dir, "sp.crt"), "CERTIFICATE", spCertificate.Raw)
+	writePEM(t, filepath.Join(dir, "sp.key"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(spKey))
+	metadata, err := xml.Marshal(idp.idp.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "idp.xml"), metadata, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	setConfig(t, map[string]string{
+		"OIDC_ISSUER":            "https://app.example.com",
+		"SAML_IDP_METADATA_FILE": filepath.Join(dir, "idp.xml"),
+		"SAML_SP_CERT_FILE":      filepath.Join(dir, "sp.crt"),
+		"SAML_SP_KEY_FILE":       filepath.Join(dir, "sp.key"),
+		"SAML_GROUP_ROLES":       "Engineering=editor;Admins=admin",
+	})
+	if err := InitSAML(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { samlSP = nil })
+	return idp
+}
+
+func writePEM(t *testing.T, name string, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testSAMLSession(email string, groups ...string) *saml.Session {
+	attribute := func(name string, values ...string) saml.Attribute {
+		attribute := saml.Attribute{Name: name, NameFormat: "urn:oasis:names:tc:SAML:2.0:attrname-format:basic"}
+		for _, value := range values {
+			attribute.Values = append(attribute.Values, saml.AttributeValue{Type: "xs:string", Value: value})
+		}
+		return attribute
+	}
+	return &saml.Session{
+		ID:           "session-1",
+		CreateTime:   time.Now(),
+		Index:        "1",
+		NameID:       "dave-persistent-id",
+		NameIDFormat: string(saml.PersistentNameIDFormat),
+		CustomAttributes: []saml.Attribute{
+			attribute("email", email),
+			attribute("firstName", "Dave"),
+			attribute("groups", groups...),
+		},
+	}
+}
+
+// postSAMLResponse builds the request the browser makes to the ACS endpoint.
+func postSAMLResponse(samlResponse string) *http.Request {
+	form := url.Values{"SAMLResponse": {samlResponse}}
+	r := httptest.NewRequest(http.MethodPost, "https://app.example.com/saml/acs", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestSAMLLoginRegistersUserWithMappedRoles(t *testing.T) {
+	idp := setupTestSAML(t)
+	if _, err := CreateRole("editor", "", nil, "", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	redirectURL, requestID, err := BeginSAMLLogin()
+	if err != nil {
+		t.Fatalf("BeginSAMLLogin: %v", err)
+	}
+	samlResponse := idp.respond(redirectURL, testSAMLSession("dave@example.com", "Engineering"))
+
+	user, err := FinishSAMLLogin(postSAMLResponse(samlResponse), []string{requestID}, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("FinishSAMLLogin: %v", err)
+	}
+	if user.Email != "dave@example.com" || user.FirstName != "Dave" || !user.EmailVerified {
+		t.Errorf("user = %q %q verified=%v, want the assertion's profile", user.Email, user.FirstName, user.EmailVerified)
+	}
+	if err := LoadUserRoles(user); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(user.RoleNames(), "editor") || slices.Contains(user.RoleNames(), models.RoleAdmin) {
+		t.Errorf("roles = %v, want the editor role mapped from the group", user.RoleNames())
+	}
+}
+
+func TestSAMLLoginMapsAdminGroup(t *testing.T) {
+	idp := setupTestSAML(t)
+
+	redirectURL, requestID, err := BeginSAMLLogin()
+	if err != nil {
+		t.Fatalf("BeginSAMLLogin: %v", err)
+	}
+	samlResponse := idp.respond(redirectURL, testSAMLSession("dave@example.com", "Admins"))
+
+	user, err := FinishSAMLLogin(postSAMLResponse(samlResponse), []string{requestID}, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("FinishSAMLLogin: %v", err)
+	}
+	if err := LoadUserRoles(user); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(user.RoleNames(), models.RoleAdmin) {
+		t.Errorf("roles = %v, want the admin role mapped from the group", user.RoleNames())
+	}
+}
+
+func TestSAMLLoginRejectsTamperedAssertion(t *testing.T) {
+	idp := setupTestSAML(t)
+
+	redirectURL, requestID, err := BeginSAMLLogin()
+	if err != nil {
+		t.Fatalf("BeginSAMLLogin: %v", err)
+	}
+	samlResponse := idp.respond(redirectURL, testSAMLSession("dave@example.com"))
+	response, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(response), "dave@example.com", "admin@example.com", 1)
+	if tampered == string(response) {
+		t.Fatal("response does not contain the email address")
+	}
+
+	_, err = FinishSAMLLogin(postSAMLResponse(base64.StdEncoding.EncodeToString([]byte(tampered))), []string{requestID}, "127.0.0.1")
+	if !errors.Is(err, ErrSAMLAssertionInvalid) {
+		t.Errorf("FinishSAMLLogin = %v, want ErrSAMLAssertionInvalid", err)
+	}
+}
+
+func TestSAMLLoginRejectsAssertionFromOtherIdP(t *testing.T) {
+	setupTestSAML(t)
+	// An IdP with the same entity ID but a key the metadata does not trust.
+	impostor := newTestSAMLIdP(t)
+	impostor.idp.ServiceProviderProvider = impostor
+
+	redirectURL, requestID, err := BeginSAMLLogin()
+	if err != nil {
+		t.Fatalf("BeginSAMLLogin: %v", err)
+	}
+	samlResponse := impostor.respond(redirectURL, testSAMLSession("dave@example.com"))
+
+	_, err = FinishSAMLLogin(postSAMLResponse(samlResponse), []string{requestID}, "127.0.0.1")
+	if !errors.Is(err, ErrSAMLAssertionInvalid) {
+		t.Errorf("FinishSAMLLogin = %v, want ErrSAMLAssertionInvalid", err)
+	}
+}
+
+func TestSAMLLoginRejectsReplayedResponse(t *testing.T) {
+	idp := setupTestSAML(t)
+
+	redirectURL, requestID, err := BeginSAMLLogin()
+	if err != nil {
+		t.Fatalf("BeginSAMLLogin: %v", err)
+	}
+	samlResponse := idp.respond(redirectURL, testSAMLSession("dave@example.com"))
+	if _, err := FinishSAMLLogin(postSAMLResponse(samlResponse), []string{requestID}, "127.0.0.1"); err != nil {
+		t.Fatalf("FinishSAMLLogin: %v", err)
+	}
+
+	_, err = FinishSAMLLogin(postSAMLResponse(samlResponse), []string{requestID}, "127.0.0.1")
+	if !errors.Is(err, ErrSAMLAssertionInvalid) {
+		t.Errorf("FinishSAMLLogin with a replayed response = %v, want ErrSAMLAssertionInvalid", err)
+	}
+}
+
+func TestSAMLLoginRejectsReplayedIDPInitiatedAssertion(t *testing.T) {
+	idp := setupTestSAML(t)
+	samlSP.AllowIDPInitiated = true
+
+	redirectURL, _, err := BeginSAMLLogin()
+	if err != nil {
+		t.Fatalf("BeginSAMLLogin: %v", err)
+	}
+	samlResponse := idp.respond(redirectURL, testSAMLSession("dave@example.com"))
+	if _, err := FinishSAMLLogin(postSAMLResponse(samlResponse), nil, "127.0.0.1"); err != nil {
+		t.Fatalf("FinishSAMLLogin: %v", err)
+	}
+
+	_, err = FinishSAMLLogin(postSAMLResponse(samlResponse), nil, "127.0.0.1")
+	if !errors.Is(err, ErrSAMLAssertionInvalid) {
+		t.Errorf("FinishSAMLLogin with a replayed assertion = %v, want ErrSAMLAssertionInvalid", err)
+	}
+}
+
+func TestSAMLLoginRejectsRequestNotIssuedByService(t *testing.T) {
+	idp := setupTestSAML(t)
+
+	// A request the browser made up, sent along with its ID in the cookie.
+	request, err := samlSP.MakeAuthenticationRequest(
+		samlSP.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirectURL, err := request.Redirect("", samlSP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samlResponse := idp.respond(redirectURL.String(), testSAMLSession("dave@example.com"))
+
+	_, err = FinishSAMLLogin(postSAMLResponse(samlResponse), []string{request.ID}, "127.0.0.1")
+	if !errors.Is(err, ErrSAMLAssertionInvalid) {
+		t.Errorf("FinishSAMLLogin = %v, want ErrSAMLAssertionInvalid", err)
+	}
+}
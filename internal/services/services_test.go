@@ -0,0 +1,53 @@
+package services
+
+import (
+	"path/filepath"
+	"platform-service/internal/database"
+	"platform-service/internal/utils"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// setupTestDB points the database at a fresh SQLite file for the test.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	setConfig(t, map[string]string{
+		"DB_DRIVER":            "sqlite",
+		"DB_CONNECTION_STRING": filepath.Join(t.TempDir(), "test.db"),
+		"JWT_SECRET_KEY":       "test-jwt-secret-key",
+		"DATA_ENCRYPTION_KEY":  "test-data-encryption-key",
+	})
+	if err := database.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// setConfig sets configuration values until the end of the test.
+func setConfig(t *testing.T, values map[string]string) {
+	t.Helper()
+	for key, value := range values {
+		previous := viper.GetString(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, previous) })
+	}
+}
+
+// useSigningKey signs tokens with a fresh key of the algorithm until the end
+// of the test.
+func useSigningKey(t *testing.T, alg string) {
+	t.Helper()
+	key, err := utils.GenerateSigningKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.Keys
+	utils.Keys = utils.NewKeyring()
+	utils.Keys.Set(key, nil)
+	t.Cleanup(func() { utils.Keys = previous })
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// StartSession records a first-party login of the user from the device
+// described by userAgent and issues the refresh token family that keeps it
+// alive.
+func StartSession(user *models.User, userAgent string, ip string) (*models.Session, string, *models.RefreshToken, error) {
+	var (
+		session *models.Session
+		raw     string
+		refresh *models.RefreshToken
+	)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		raw, refresh, err = issueRefreshToken(tx, &models.RefreshToken{
+			UserID:      user.ID,
+			FamilyID:    uuid.NewString(),
+			CreatedByIP: ip,
+		})
+		if err != nil {
+			return err
+		}
+
+		session = &models.Session{
+			SessionID:  refresh.FamilyID,
+			UserID:     user.ID,
+			DeviceName: utils.DeviceName(userAgent),
+			UserAgent:  truncate(userAgent, 512),
+			IP:         ip,
+			LastSeenAt: time.Now(),
+			ExpiresAt:  refresh.ExpiresAt,
+		}
+		return tx.Create(session).Error
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return session, raw, refresh, nil
+}
+
+// TouchSession records activity on the session of a rotated refresh token
+// and returns its ID. Token families that predate sessions and those of OAuth
+// clients have no session, and an empty ID is returned for them.
+func TouchSession(refresh *models.RefreshToken, ip string) (string, error) {
+	update := database.DB.Model(&models.Session{}).
+		Where("session_id = ? AND revoked_at IS NULL", refresh.FamilyID).
+		Updates(map[string]interface{}{
+			"last_seen_at": time.Now(),
+			"ip":           ip,
+			"expires_at":   refresh.ExpiresAt,
+		})
+	if update.Error != nil {
+		return "", update.Error
+	}
+	if update.RowsAffected == 0 {
+		return "", nil
+	}
+	return refresh.FamilyID, nil
+}
+
+// ListSessions returns the user's active sessions, most recently used first.
+func ListSessions(user *models.User) ([]models.Session, error) {
+	var sessions []models.Session
+	err := database.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession signs the user out of one session, revoking its refresh
+// tokens and access tokens.
+func RevokeSession(user *models.User, sessionID string) error {
+	session := new(models.Session)
+	err := database.DB.Where("session_id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, user.ID).
+		First(session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSessionNotFound
+	} else if err != nil {
+		return err
+	}
+	return revokeSessions(user, []models.Session{*session})
+}
+
+// RevokeOtherSessions signs the user out of every session except the current
+// one.
+func RevokeOtherSessions(user *models.User, currentSessionID string) error {
+	var sessions []models.Session
+	err := database.DB.Where("user_id = ? AND session_id <> ? AND revoked_at IS NULL", user.ID, currentSessionID).
+		Find(&sessions).Error
+	if err != nil {
+		return err
+	}
+	return revokeSessions(user, sessions)
+}
+
+func revokeSessions(user *models.User, sessions []models.Session) error {
+	for _, session := range sessions {
+		if err := Revocations.RevokeSession(session.SessionID, user.UID); err != nil {
+			return err
+		}
+		if err := RevokeRefreshTokenFamily(session.SessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endSessions marks the matching sessions as revoked. Their tokens must be
+// revoked separately.
+func endSessions(query interface{}, args ...interface{}) error {
+	return database.DB.Model(&models.Session{}).
+		Where(query, args...).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const testUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15"
+
+func TestRevokeSessionEndsOneSession(t *testing.T) {
+	setupTestDB(t)
+	useTestRevocations(t)
+	alice := registerTestUser(t, "alice")
+	bob := registerTestUser(t, "bob")
+
+	session, raw, _, err := StartSession(alice, testUserAgent, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, otherRaw, _, err := StartSession(alice, testUserAgent, "127.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RevokeSession(bob, session.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession of another user's session = %v, want ErrSessionNotFound", err)
+	}
+	if err := RevokeSession(alice, session.SessionID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if err := RevokeSession(alice, session.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession of a revoked session = %v, want ErrSessionNotFound", err)
+	}
+
+	if !Revocations.IsRevoked(accessClaims(alice.UID, "jti-1", session.SessionID, time.Now())) {
+		t.Error("access token of the revoked session is not revoked")
+	}
+	if Revocations.IsRevoked(accessClaims(alice.UID, "jti-2", other.SessionID, time.Now())) {
+		t.Error("access token of the other session is revoked")
+	}
+	if _, _, err := RotateRefreshToken(raw, "", "127.0.0.1"); err == nil {
+		t.Error("refresh token of the revoked session still rotates")
+	}
+	if _, _, err := RotateRefreshToken(otherRaw, "", "127.0.0.2"); err != nil {
+		t.Errorf("refresh token of the other session: %v", err)
+	}
+
+	sessions, err := ListSessions(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].SessionID != other.SessionID {
+		t.Errorf("ListSessions = %v, want only the other session", sessions)
+	}
+}
+
+func TestRevokeOtherSessionsKeepsCurrentSession(t *testing.T) {
+	setupTestDB(t)
+	useTestRevocations(t)
+	alice := registerTestUser(t, "alice")
+	bob := registerTestUser(t, "bob")
+
+	current, currentRaw, _, err := StartSession(alice, testUserAgent, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var others []string
+	for i := 0; i < 2; i++ {
+		session, _, _, err := StartSession(alice, testUserAgent, "127.0.0.2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		others = append(others, session.SessionID)
+	}
+	bobSession, _, _, err := StartSession(bob, testUserAgent, "127.0.0.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RevokeOtherSessions(alice, current.SessionID); err != nil {
+		t.Fatalf("RevokeOtherSessions: %v", err)
+	}
+	for _, sessionID := range others {
+		if !Revocations.IsRevoked(accessClaims(alice.UID, "", sessionID, time.Now())) {
+			t.Errorf("access token of session %s is not revoked", sessionID)
+		}
+	}
+	if Revocations.IsRevoked(accessClaims(alice.UID, "", current.SessionID, time.Now())) {
+		t.Error("access token of the current session is revoked")
+	}
+	if Revocations.IsRevoked(accessClaims(bob.UID, "", bobSession.SessionID, time.Now())) {
+		t.Error("access token of another user's session is revoked")
+	}
+	if _, _, err := RotateRefreshToken(currentRaw, "", "127.0.0.1"); err != nil {
+		t.Errorf("refresh token of the current session: %v", err)
+	}
+
+	sessions, err := ListSessions(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].SessionID != current.SessionID {
+		t.Errorf("ListSessions = %v, want only the current session", sessions)
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Token type hints of RFC 7662 and RFC 7009.
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+var ErrTokenInactive = errors.New("token is not active")
+
+// TokenIntrospection describes an active token in the terms of RFC 7662.
+// User is set, with roles loaded, for refresh tokens.
+type TokenIntrospection struct {
+	Claims       *models.JwtCustomClaims
+	RefreshToken *models.RefreshToken
+	User         *models.User
+}
+
+// IntrospectToken returns what is known about a token the client presents,
+// trying the kind named by hint first. Access tokens must be signed by us,
+// unexpired and not revoked. Refresh tokens must be unused, unexpired and
+// issued to the client. Either kind of token of a user who is no longer
+// active is inactive. Anything else returns ErrTokenInactive.
+func IntrospectToken(client *models.OAuthClient, raw string, hint string) (*TokenIntrospection, error) {
+	if hint == TokenTypeHintRefreshToken {
+		introspection, err := introspectRefreshToken(client, raw)
+		if !errors.Is(err, ErrTokenInactive) {
+			return introspection, err
+		}
+		return introspectAccessToken(raw)
+	}
+
+	introspection, err := introspectAccessToken(raw)
+	if !errors.Is(err, ErrTokenInactive) {
+		return introspection, err
+	}
+	return introspectRefreshToken(client, raw)
+}
+
+func introspectAccessToken(raw string) (*TokenIntrospection, error) {
+	claims, err := parseAccessToken(raw)
+	if err != nil {
+		return nil, err
+	}
+	if Revocations.IsRevoked(claims) {
+		return nil, ErrTokenInactive
+	}
+	if !claims.IsService() {
+		user := new(models.User)
+		if err := database.DB.Where("uid = ?", claims.UserID).First(user).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTokenInactive
+		} else if err != nil {
+			return nil, err
+		}
+		if !user.IsActive() {
+			return nil, ErrTokenInactive
+		}
+	}
+	return &TokenIntrospection{Claims: claims}, nil
+}
+
+// parseAccessToken returns the claims of an access token signed by us that
+// has not expired.
+func parseAccessToken(raw string) (*models.JwtCustomClaims, error) {
+	token, err := utils.ValidateJWT(raw)
+	if err != nil || !token.Valid {
+		return nil, ErrTokenInactive
+	}
+	claims, ok := token.Claims.(*models.JwtCustomClaims)
+	if !ok {
+		return nil, ErrTokenInactive
+	}
+	return claims, nil
+}
+
+func introspectRefreshToken(client *models.OAuthClient, raw string) (*TokenIntrospection, error) {
+	token, err := findClientRefreshToken(client, raw)
+	if err != nil {
+		return nil, err
+	}
+	// Rotation also revokes the token, but a rotated token is never active.
+	if token.IsRevoked() || token.IsRotated() || token.IsExpired() {
+		return nil, ErrTokenInactive
+	}
+
+	user := new(models.User)
+	if err := database.DB.First(user, token.UserID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTokenInactive
+	} else if err != nil {
+		return nil, err
+	}
+	if !user.IsActive() {
+		return nil, ErrTokenInactive
+	}
+	if err := LoadUserRoles(user); err != nil {
+		return nil, err
+	}
+	return &TokenIntrospection{RefreshToken: token, User: user}, nil
+}
+
+// findClientRefreshToken returns the refresh token if it was issued to the
+// client, and ErrTokenInactive otherwise.
+func findClientRefreshToken(client *models.OAuthClient, raw string) (*models.RefreshToken, error) {
+	token := new(models.RefreshToken)
+	err := database.DB.Where("token_hash = ?", utils.HashToken(raw)).First(token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTokenInactive
+	} else if err != nil {
+		return nil, err
+	}
+	if token.ClientID == "" || token.ClientID != client.ClientID {
+		return nil, ErrTokenInactive
+	}
+	return token, nil
+}
+
+// RevokeClientToken revokes a token issued to the client, trying the kind
+// named by hint first. Revoking a refresh token revokes its whole family.
+// Tokens that are unknown, already inactive or issued to another client are
+// ignored, as RFC 7009 asks.
+func RevokeClientToken(client *models.OAuthClient, raw string, hint string) error {
+	if hint == TokenTypeHintRefreshToken {
+		if revoked, err := revokeClientRefreshToken(client, raw); revoked || err != nil {
+			return err
+		}
+		_, err := revokeClientAccessToken(client, raw)
+		return err
+	}
+
+	if revoked, err := revokeClientAccessToken(client, raw); revoked || err != nil {
+		return err
+	}
+	_, err := revokeClientRefreshToken(client, raw)
+	return err
+}
+
+func revokeClientAccessToken(client *models.OAuthClient, raw string) (bool, error) {
+	claims, err := parseAccessToken(raw)
+	if errors.Is(err, ErrTokenInactive) {
+		return false, nil
+	}
+	if claims.ClientID != client.ClientID || claims.ID == "" || claims.ExpiresAt == nil {
+		return false, nil
+	}
+	if Revocations.IsRevoked(claims) {
+		return true, nil
+	}
+	return true, Revocations.RevokeToken(claims.ID, claims.PrincipalID(), claims.ExpiresAt.Time)
+}
+
+func revokeClientRefreshToken(client *models.OAuthClient, raw string) (bool, error) {
+	token, err := findClientRefreshToken(client, raw)
+	if errors.Is(err, ErrTokenInactive) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, RevokeRefreshTokenFamily(token.FamilyID)
+}
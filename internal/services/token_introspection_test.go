@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestIntrospectRotatedRefreshToken(t *testing.T) {
+	setupTestDB(t)
+	user := registerTestUser(t, "alice")
+	client := &models.OAuthClient{ClientID: "app", Name: "App"}
+
+	raw, _, err := IssueClientRefreshToken(user.ID, client.ClientID, "openid offline_access", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := IntrospectToken(client, raw, TokenTypeHintRefreshToken); err != nil {
+		t.Fatalf("IntrospectToken of a fresh refresh token: %v", err)
+	}
+	successor, _, err := RotateRefreshToken(raw, client.ClientID, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := IntrospectToken(client, raw, TokenTypeHintRefreshToken); !errors.Is(err, ErrTokenInactive) {
+		t.Errorf("IntrospectToken of a rotated refresh token = %v, want ErrTokenInactive", err)
+	}
+	if _, err := IntrospectToken(client, successor, TokenTypeHintRefreshToken); err != nil {
+		t.Errorf("IntrospectToken of the successor: %v", err)
+	}
+	if _, err := IntrospectToken(&models.OAuthClient{ClientID: "other"}, successor, TokenTypeHintRefreshToken); !errors.Is(err, ErrTokenInactive) {
+		t.Errorf("IntrospectToken by another client = %v, want ErrTokenInactive", err)
+	}
+}
+
+func TestIntrospectTokensOfInactiveUser(t *testing.T) {
+	setupTestDB(t)
+	useSigningKey(t, "HS256")
+	user := registerTestUser(t, "alice")
+	client := &models.OAuthClient{ClientID: "app", Name: "App"}
+
+	accessToken, err := utils.GenerateJWT(user.UID, user.Username, time.Now().Add(time.Hour), utils.WithClientID(client.ClientID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshToken, _, err := IssueClientRefreshToken(user.ID, client.ClientID, "offline_access", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := IntrospectToken(client, accessToken, TokenTypeHintAccessToken); err != nil {
+		t.Fatalf("IntrospectToken of an active user's access token: %v", err)
+	}
+
+	if err := database.DB.Model(user).Update("status", models.UserStatusDisabled).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := IntrospectToken(client, accessToken, TokenTypeHintAccessToken); !errors.Is(err, ErrTokenInactive) {
+		t.Errorf("IntrospectToken of a disabled user's access token = %v, want ErrTokenInactive", err)
+	}
+	if _, err := IntrospectToken(client, refreshToken, TokenTypeHintRefreshToken); !errors.Is(err, ErrTokenInactive) {
+		t.Errorf("IntrospectToken of a disabled user's refresh token = %v, want ErrTokenInactive", err)
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/passwords"
+	"platform-service/internal/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FindUserByIdentifier returns the user whose username or email address
+// matches the identifier, ignoring case and surrounding whitespace. A
+// username match wins over an email match, so that a username that looks like
+// an email address cannot be shadowed by another account's address.
+func FindUserByIdentifier(identifier string) (*models.User, error) {
+	normalized := models.NormalizeIdentifier(identifier)
+	if normalized == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	user := new(models.User)
+	err := database.DB.Where("normalized_username = ?", normalized).First(user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = database.DB.Where("normalized_email = ?", normalized).First(user).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+var ErrUserExists = errors.New("username or email already exists")
+
+// NewUser describes an account to register. Password may be empty for users
+// who sign in through an identity provider; they get a random password they
+// can replace with a password reset.
+type NewUser struct {
+	Username      string
+	Password      string
+	Email         string
+	FirstName     string
+	LastName      string
+	ProfileImage  string
+	EmailVerified bool
+	IP            string
+}
+
+// RegisterUser creates an account. When email verification is required and
+// the address is not known to be verified, the account waits for
+// verification. A verification email is sent for unverified addresses.
+func RegisterUser(params NewUser) (*models.User, error) {
+	// Usernames and email addresses share one namespace at login, so neither
+	// may match another account's username or email address.
+	identifiers := []string{models.NormalizeIdentifier(params.Username), models.NormalizeIdentifier(params.Email)}
+	var existingUser models.User
+	err := database.DB.Where("normalized_username IN ? OR normalized_email IN ?", identifiers, identifiers).
+		First(&existingUser).Error
+	if err == nil {
+		return nil, ErrUserExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	password := params.Password
+	if password == "" {
+		if password, err = utils.GenerateOpaqueToken(32); err != nil {
+			return nil, err
+		}
+	}
+	hashedPassword, err := passwords.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	status := models.UserStatusActive
+	if config.IsEmailVerificationRequired() && !params.EmailVerified {
+		status = models.UserStatusPendingVerification
+	}
+
+	user := &models.User{
+		Username:      params.Username,
+		Password:      hashedPassword,
+		Email:         params.Email,
+		FirstName:     params.FirstName,
+		LastName:      params.LastName,
+		ProfileImage:  params.ProfileImage,
+		Status:        status,
+		EmailVerified: params.EmailVerified,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		LastIP:        params.IP,
+		UID:           uuid.NewString(),
+	}
+
+	tx := database.DB.Begin()
+	if err := tx.Create(user).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	if !user.EmailVerified {
+		if err := SendVerificationEmail(user); err != nil {
+			log.Printf("Error sending verification email to user %s: %v", user.UID, err)
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"platform-service/internal/config"
+	"platform-service/internal/database"
+	"platform-service/internal/models"
+	"platform-service/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/gorm"
+)
+
+const webAuthnSessionBytes = 32
+
+var (
+	ErrWebAuthnSessionInvalid = errors.New("passkey ceremony is invalid or expired")
+	ErrPasskeyInvalid         = errors.New("passkey verification failed")
+	ErrPasskeyNotFound        = errors.New("passkey not found")
+)
+
+var webAuthn *webauthn.WebAuthn
+
+// InitWebAuthn configures the relying party used for passkey ceremonies.
+func InitWebAuthn() error {
+	timeout := webauthn.TimeoutConfig{
+		Enforce:    true,
+		Timeout:    config.GetWebAuthnTimeout(),
+		TimeoutUVD: config.GetWebAuthnTimeout(),
+	}
+
+	var err error
+	webAuthn, err = webauthn.New(&webauthn.Config{
+		RPID:          config.GetWebAuthnRPID(),
+		RPDisplayName: config.GetWebAuthnRPName(),
+		RPOrigins:     config.GetWebAuthnOrigins(),
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	return err
+}
+
+// webAuthnUser adapts a user and their stored credentials to webauthn.User.
+// The user handle is the UID, so passwordless logins can find the account
+// from the handle the authenticator returns.
+type webAuthnUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.user.UID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if name := u.user.FullName(); name != "" {
+		return name
+	}
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func loadWebAuthnUser(user *models.User) (*webAuthnUser, error) {
+	stored, err := ListPasskeys(user)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, credential := range stored {
+		decoded, err := toWebAuthnCredential(&credential)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, decoded)
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+func toWebAuthnCredential(credential *models.WebAuthnCredential) (webauthn.Credential, error) {
+	id, err := base64.RawURLEncoding.DecodeString(credential.CredentialID)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+
+	var transports []protocol.AuthenticatorTransport
+	for _, transport := range strings.Fields(credential.Transports) {
+		transports = append(transports, protocol.AuthenticatorTransport(transport))
+	}
+
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: credential.BackupEligible,
+			BackupState:    credential.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       credential.AAGUID,
+			SignCount:    credential.SignCount,
+			CloneWarning: credential.CloneWarning,
+		},
+	}, nil
+}
+
+// ListPasskeys returns the credentials registered by the user.
+func ListPasskeys(user *models.User) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := database.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&credentials).Error
+	return credentials, err
+}
+
+// DeletePasskey removes one of the user's credentials by its credential ID.
+func DeletePasskey(user *models.User, credentialID string) error {
+	result := database.DB.Unscoped().
+		Where("user_id = ? AND credential_id = ?", user.ID, credentialID).
+		Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
+
+// BeginPasskeyRegistration starts a registration ceremony for the user and
+// returns the options for navigator.credentials.create with the session
+// token that has to be presented when finishing it.
+func BeginPasskeyRegistration(user *models.User) (*protocol.CredentialCreation, string, error) {
+	waUser, err := loadWebAuthnUser(user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(waUser.credentials))
+	for _, credential := range waUser.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := webAuthn.BeginRegistration(waUser, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := createWebAuthnSession(user.ID, models.WebAuthnSessionRegister, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return creation, token, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's attestation
+// response and stores the new credential under the given name.
+func FinishPasskeyRegistration(user *models.User, token string, name string, response []byte) (*models.WebAuthnCredential, error) {
+	session, err := consumeWebAuthnSession(token, models.WebAuthnSessionRegister)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != user.ID {
+		return nil, ErrWebAuthnSessionInvalid
+	}
+	data, err := decodeSessionData(session)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, ErrPasskeyInvalid
+	}
+	waUser, err := loadWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+	created, err := webAuthn.CreateCredential(waUser, *data, parsed)
+	if err != nil {
+		return nil, ErrPasskeyInvalid
+	}
+
+	transports := make([]string, 0, len(created.Transport))
+	for _, transport := range created.Transport {
+		transports = append(transports, string(transport))
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		name = "Passkey"
+	}
+
+	credential := &models.WebAuthnCredential{
+		UserID:          user.ID,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(created.ID),
+		PublicKey:       created.PublicKey,
+		Name:            name,
+		AttestationType: created.AttestationType,
+		Transports:      strings.Join(transports, " "),
+		AAGUID:          created.Authenticator.AAGUID,
+		SignCount:       created.Authenticator.SignCount,
+		BackupEligible:  created.Flags.BackupEligible,
+		BackupState:     created.Flags.BackupState,
+	}
+	if err := database.DB.Create(credential).Error; err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// BeginPasskeyLogin starts a login ceremony that asks the browser for any
+// discoverable credential of this relying party. The account is found from
+// the credential's user handle, so the challenge is the same for everyone and
+// does not reveal which accounts exist or have passkeys.
+func BeginPasskeyLogin() (*protocol.CredentialAssertion, string, error) {
+	assertion, session, err := webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := createWebAuthnSession(0, models.WebAuthnSessionLogin, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return assertion, token, nil
+}
+
+// FinishPasskeyLogin verifies the authenticator's assertion and returns the
+// user it belongs to. Assertions from credentials that look cloned are
+// rejected.
+func FinishPasskeyLogin(token string, response []byte) (*models.User, error) {
+	session, err := consumeWebAuthnSession(token, models.WebAuthnSessionLogin)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeSessionData(session)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, ErrPasskeyInvalid
+	}
+
+	found, validated, err := webAuthn.ValidatePasskeyLogin(findWebAuthnUserByHandle, *data, parsed)
+	if err != nil {
+		return nil, ErrPasskeyInvalid
+	}
+	waUser := found.(*webAuthnUser)
+
+	credentialID := base64.RawURLEncoding.EncodeToString(validated.ID)
+	now := time.Now()
+	err = database.DB.Model(&models.WebAuthnCredential{}).
+		Where("user_id = ? AND credential_id = ?", waUser.user.ID, credentialID).
+		Updates(map[string]interface{}{
+			"sign_count":    validated.Authenticator.SignCount,
+			"clone_warning": validated.Authenticator.CloneWarning,
+			"backup_state":  validated.Flags.BackupState,
+			"last_used_at":  now,
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	if validated.Authenticator.CloneWarning {
+		return nil, ErrPasskeyInvalid
+	}
+	return waUser.user, nil
+}
+
+func findWebAuthnUserByHandle(rawID, userHandle []byte) (webauthn.User, error) {
+	user := new(models.User)
+	if err := database.DB.Where("uid = ?", string(userHandle)).First(user).Error; err != nil {
+		return nil, err
+	}
+	return loadWebAuthnUser(user)
+}
+
+func createWebAuthnSession(userID uint, purpose string, data *webauthn.SessionData) (string, error) {
+	raw, err := utils.GenerateOpaqueToken(webAuthnSessionBytes)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	session := &models.WebAuthnSession{
+		TokenHash: utils.HashToken(raw),
+		UserID:    userID,
+		Purpose:   purpose,
+		Data:      string(encoded),
+		ExpiresAt: time.Now().Add(config.GetWebAuthnTimeout()),
+	}
+	if err := database.DB.Create(session).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeWebAuthnSession marks the session as used so that each challenge
+// can only be answered once, whether or not the answer verifies.
+func consumeWebAuthnSession(raw string, purpose string) (*models.WebAuthnSession, error) {
+	session := new(models.WebAuthnSession)
+	err := database.DB.Where("token_hash = ? AND purpose = ?", utils.HashToken(raw), purpose).First(session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWebAuthnSessionInvalid
+	} else if err != nil {
+		return nil, err
+	}
+	if session.UsedAt != nil || session.IsExpired() {
+		return nil, ErrWebAuthnSessionInvalid
+	}
+
+	update := database.DB.Model(&models.WebAuthnSession{}).
+		Where("id = ? AND used_at IS NULL", session.ID).
+		Update("used_at", time.Now())
+	if update.Error != nil {
+		return nil, update.Error
+	}
+	if update.RowsAffected == 0 {
+		return nil, ErrWebAuthnSessionInvalid
+	}
+	return session, nil
+}
+
+func decodeSessionData(session *models.WebAuthnSession) (*webauthn.SessionData, error) {
+	data := new(webauthn.SessionData)
+	if err := json.Unmarshal([]byte(session.Data), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
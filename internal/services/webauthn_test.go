@@ -0,0 +1,230 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"platform-service/internal/models"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+const testWebAuthnOrigin = "https://app.example.com"
+
+// softwareAuthenticator is a passkey held in memory. It answers ceremonies
+// the way a browser and a platform authenticator would together.
+type softwareAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &softwareAuthenticator{t: t, key: key, credentialID: credentialID}
+}
+
+func (a *softwareAuthenticator) clientData(ceremony string, challenge []byte) []byte {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      testWebAuthnOrigin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+// authenticatorData returns the data the authenticator signs: the RP ID
+// hash, the user present and user verified flags and the sign count,
+// followed by the attested credential on registration.
+func (a *softwareAuthenticator) authenticatorData(rpID string, attestedCredential []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attestedCredential != nil {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attestedCredential...)
+}
+
+// register answers the options of navigator.credentials.create() with a
+// "none" attestation.
+func (a *softwareAuthenticator) register(options *protocol.CredentialCreation) []byte {
+	a.t.Helper()
+	a.userHandle = []byte(options.Response.User.ID.(protocol.URLEncodedBase64))
+
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authenticatorData(options.Response.RelyingParty.ID, attested),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.credential(map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(a.clientData("webauthn.create", options.Response.Challenge)),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+	})
+}
+
+// login answers the options of navigator.credentials.get().
+func (a *softwareAuthenticator) login(options *protocol.CredentialAssertion) []byte {
+	a.t.Helper()
+	a.signCount++
+	authData := a.authenticatorData(options.Response.RelyingPartyID, nil)
+	clientData := a.clientData("webauthn.get", options.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.credential(map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softwareAuthenticator) credential(response map[string]string) []byte {
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+func setupTestWebAuthn(t *testing.T) *models.User {
+	t.Helper()
+	setupTestDB(t)
+	setConfig(t, map[string]string{
+		"WEBAUTHN_RP_ID":      "app.example.com",
+		"WEBAUTHN_RP_ORIGINS": testWebAuthnOrigin,
+	})
+	if err := InitWebAuthn(); err != nil {
+		t.Fatal(err)
+	}
+	user, err := RegisterUser(NewUser{
+		Username:      "alice",
+		Email:         "alice@example.com",
+		Password:      "correct horse battery staple",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// registerPasskey runs a registration ceremony for the user.
+func registerPasskey(t *testing.T, user *models.User, authenticator *softwareAuthenticator) {
+	t.Helper()
+	options, session, err := BeginPasskeyRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	if _, err := FinishPasskeyRegistration(user, session, "Laptop", authenticator.register(options)); err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	user := setupTestWebAuthn(t)
+	authenticator := newSoftwareAuthenticator(t)
+	registerPasskey(t, user, authenticator)
+
+	for i := 0; i < 2; i++ {
+		options, session, err := BeginPasskeyLogin()
+		if err != nil {
+			t.Fatalf("BeginPasskeyLogin: %v", err)
+		}
+		if len(options.Response.AllowedCredentials) != 0 {
+			t.Errorf("login options list %d credentials, want none", len(options.Response.AllowedCredentials))
+		}
+		loggedIn, err := FinishPasskeyLogin(session, authenticator.login(options))
+		if err != nil {
+			t.Fatalf("FinishPasskeyLogin: %v", err)
+		}
+		if loggedIn.UID != user.UID {
+			t.Errorf("logged in as %s, want %s", loggedIn.UID, user.UID)
+		}
+	}
+}
+
+func TestPasskeyLoginRejectsUnregisteredKey(t *testing.T) {
+	user := setupTestWebAuthn(t)
+	authenticator := newSoftwareAuthenticator(t)
+	registerPasskey(t, user, authenticator)
+
+	// A different key claiming the registered credential ID.
+	impostor := newSoftwareAuthenticator(t)
+	impostor.credentialID = authenticator.credentialID
+	impostor.userHandle = authenticator.userHandle
+
+	options, session, err := BeginPasskeyLogin()
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	if _, err := FinishPasskeyLogin(session, impostor.login(options)); !errors.Is(err, ErrPasskeyInvalid) {
+		t.Errorf("FinishPasskeyLogin = %v, want ErrPasskeyInvalid", err)
+	}
+}
+
+func TestPasskeyLoginRejectsReplayedSession(t *testing.T) {
+	user := setupTestWebAuthn(t)
+	authenticator := newSoftwareAuthenticator(t)
+	registerPasskey(t, user, authenticator)
+
+	options, session, err := BeginPasskeyLogin()
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	assertion := authenticator.login(options)
+	if _, err := FinishPasskeyLogin(session, assertion); err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if _, err := FinishPasskeyLogin(session, assertion); err == nil {
+		t.Error("FinishPasskeyLogin accepted a replayed session")
+	}
+}
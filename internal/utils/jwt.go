@@ -3,22 +3,44 @@ package utils
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
 	"platform-service/internal/config"
 	"platform-service/internal/models"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 )
 
-var jwtSecret []byte
+// Keys is the keyring used to sign and verify tokens. It starts out empty
+// until services.LoadKeyring loads the persisted keyring.
+var Keys = NewKeyring()
 
-func init() {
-	jwtSecret = []byte(config.GetJWTSecretKey())
-	if len(jwtSecret) == 0 {
-		panic("JWT_SECRET_KEY is not set!")
+// LoadConfiguredSigningKey loads the key described by JWT_SIGNING_ALG and
+// JWT_SECRET_KEY or JWT_PRIVATE_KEY_FILE.
+func LoadConfiguredSigningKey() (*SigningKey, error) {
+	alg := config.GetJWTSigningAlgorithm()
+	if alg == jwt.SigningMethodHS256.Alg() {
+		secret := []byte(config.GetJWTSecretKey())
+		if len(secret) == 0 {
+			return nil, errors.New("JWT_SECRET_KEY is not set")
+		}
+		return NewHMACSigningKey(secret), nil
 	}
+
+	path := config.GetJWTPrivateKeyFile()
+	if path == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", alg)
+	}
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT private key: %w", err)
+	}
+	return ParseSigningKey(alg, pemBytes)
 }
 
 func GenerateKeyID(secret []byte) string {
@@ -26,33 +48,228 @@ func GenerateKeyID(secret []byte) string {
 	return hex.EncodeToString(hash[:8])
 }
 
-func GenerateJWT(userID string, username string, role string, expiredAt time.Time) (string, error) {
+// TokenOption customizes the claims of an access token.
+type TokenOption func(*models.JwtCustomClaims)
+
+// WithAudience restricts the token to the given audience.
+func WithAudience(audience string) TokenOption {
+	return func(claims *models.JwtCustomClaims) {
+		claims.Audience = jwt.ClaimStrings{audience}
+	}
+}
+
+// WithScope records the space separated scopes granted to the token.
+func WithScope(scope string) TokenOption {
+	return func(claims *models.JwtCustomClaims) {
+		claims.Scope = scope
+	}
+}
+
+// WithClientID records the OAuth client the token was issued to.
+func WithClientID(clientID string) TokenOption {
+	return func(claims *models.JwtCustomClaims) {
+		claims.ClientID = clientID
+	}
+}
+
+// WithSession binds the token to a first-party login session.
+func WithSession(sessionID string) TokenOption {
+	return func(claims *models.JwtCustomClaims) {
+		claims.SessionID = sessionID
+	}
+}
+
+// WithActor marks the token as issued to the admin with the given UID acting
+// as the token's user.
+func WithActor(uid string, username string) TokenOption {
+	return func(claims *models.JwtCustomClaims) {
+		claims.Actor = &models.Actor{Subject: uid, Username: username}
+	}
+}
+
+// WithRoles records the roles and effective permissions of the user, whose
+// roles must be loaded.
+func WithRoles(user *models.User) TokenOption {
+	return func(claims *models.JwtCustomClaims) {
+		claims.Roles = user.RoleNames()
+		claims.Permissions = user.PermissionNames()
+	}
+}
+
+func GenerateJWT(userID string, username string, expiredAt time.Time, opts ...TokenOption) (string, error) {
+	claims := &models.JwtCustomClaims{
+		UserID:        userID,
+		Username:      username,
+		TokenUse:      models.TokenUseAccess,
+		PrincipalType: models.PrincipalUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.GetOIDCIssuer(),
+			ExpiresAt: jwt.NewNumericDate(expiredAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+		},
+	}
+	for _, opt := range opts {
+		opt(claims)
+	}
+	return signToken(claims)
+}
+
+// GenerateServiceJWT issues an access token for an OAuth client acting as a
+// service principal.
+func GenerateServiceJWT(clientID string, name string, scope string, audience string, expiredAt time.Time) (string, error) {
 	claims := &models.JwtCustomClaims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		Username:      name,
+		TokenUse:      models.TokenUseAccess,
+		Scope:         scope,
+		PrincipalType: models.PrincipalService,
+		ClientID:      clientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.GetOIDCIssuer(),
+			Subject:   clientID,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expiredAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+		},
+	}
+	return signToken(claims)
+}
+
+// GenerateIDToken issues an OpenID Connect ID token describing the user. When
+// scope is not empty, profile and email claims are only included if the
+// matching scope was granted.
+func GenerateIDToken(user *models.User, audience string, scope string, nonce string, authTime time.Time, expiredAt time.Time) (string, error) {
+	claims := &models.IDTokenClaims{
+		Nonce:    nonce,
+		AuthTime: jwt.NewNumericDate(authTime),
+		TokenUse: models.TokenUseID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.GetOIDCIssuer(),
+			Subject:   user.UID,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expiredAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	if scope == "" || models.HasScope(scope, "profile") {
+		claims.Name = user.FullName()
+		claims.GivenName = user.FirstName
+		claims.FamilyName = user.LastName
+		claims.PreferredUsername = user.Username
+		claims.Picture = user.ProfileImage
+	}
+	if scope == "" || models.HasScope(scope, "email") {
+		claims.Email = user.Email
+		claims.EmailVerified = &user.EmailVerified
+	}
+	return signToken(claims)
+}
+
+// GenerateActionToken signs a token that lets the holder perform the action
+// named by tokenUse on behalf of the subject, such as verifying an email
+// address.
+func GenerateActionToken(tokenUse string, subject string, email string, expiredAt time.Time) (string, error) {
+	claims := &models.ActionTokenClaims{
+		Email:    email,
+		TokenUse: tokenUse,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.GetOIDCIssuer(),
+			Subject:   subject,
 			ExpiresAt: jwt.NewNumericDate(expiredAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
 		},
 	}
-	secret := []byte(config.GetJWTSecretKey())
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token.Header["kid"] = GenerateKeyID(secret)
-	return token.SignedString(secret)
+	return signToken(claims)
+}
+
+// ParseActionToken verifies an action token and checks that it was issued
+// for tokenUse.
+func ParseActionToken(tokenString string, tokenUse string) (*models.ActionTokenClaims, error) {
+	claims := new(models.ActionTokenClaims)
+	_, err := jwt.ParseWithClaims(tokenString, claims, verificationKey,
+		jwt.WithIssuer(config.GetOIDCIssuer()),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenUse != tokenUse {
+		return nil, errors.New("token was issued for a different purpose")
+	}
+	return claims, nil
+}
+
+func signToken(claims jwt.Claims) (string, error) {
+	key := Keys.Current()
+	if key == nil {
+		return "", errors.New("no signing key loaded")
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// verificationKey resolves the key for a token from its kid header and
+// rejects tokens whose algorithm does not match that key.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := Keys.Lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public, nil
 }
 
 func ValidateJWT(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	return jwt.ParseWithClaims(tokenString, new(models.JwtCustomClaims), verificationKey)
+}
+
+// PublicJWKS returns the public keys that verify our tokens. It is empty when
+// tokens are signed with a shared secret.
+func PublicJWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range Keys.Keys() {
+		if jwk, ok := key.JWK(); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// SigningAlgorithms returns the algorithms of the keys in the keyring.
+func SigningAlgorithms() []string {
+	var algs []string
+	seen := make(map[string]bool)
+	for _, key := range Keys.Keys() {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
 }
 
-func JWTConfig() echojwt.Config {
+// JWTConfig verifies access tokens on first-party routes. Tokens users
+// granted to an OAuth client carry the client's audience and are rejected
+// unless allowClients is set, as it is for the OpenID Connect userinfo
+// endpoint. Service principals are accepted regardless of their audience.
+func JWTConfig(allowClients bool) echojwt.Config {
 	return echojwt.Config{
-		SigningKey: []byte(config.GetJWTSecretKey()),
-		NewClaimsFunc: func(c echo.Context) jwt.Claims {
-			return new(models.JwtCustomClaims)
+		ParseTokenFunc: func(c echo.Context, auth string) (interface{}, error) {
+			token, err := ValidateJWT(auth)
+			if err != nil {
+				return nil, err
+			}
+			claims := token.Claims.(*models.JwtCustomClaims)
+			if !allowClients && !claims.IsService() && len(claims.Audience) > 0 {
+				return nil, errors.New("token was issued to an OAuth client")
+			}
+			return token, nil
 		},
 	}
 }
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+const keyringRefreshInterval = 10 * time.Second
+
+// Keyring holds the current signing key and the retired keys that are still
+// accepted for verification.
+type Keyring struct {
+	mu      sync.RWMutex
+	current *SigningKey
+	keys    map[string]*SigningKey
+
+	// Refresh reloads the keyring. It is called when a token references an
+	// unknown kid so that keys rotated by another replica are picked up
+	// before the token is rejected.
+	Refresh     func() error
+	refreshMu   sync.Mutex
+	refreshedAt time.Time
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]*SigningKey)}
+}
+
+// Set replaces the keyring contents.
+func (k *Keyring) Set(current *SigningKey, retired []*SigningKey) {
+	keys := map[string]*SigningKey{current.ID: current}
+	for _, key := range retired {
+		keys[key.ID] = key
+	}
+
+	k.mu.Lock()
+	k.current = current
+	k.keys = keys
+	k.mu.Unlock()
+}
+
+// Current returns the key new tokens are signed with.
+func (k *Keyring) Current() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Lookup returns the unexpired key with the given kid.
+func (k *Keyring) Lookup(kid string) (*SigningKey, bool) {
+	if key, ok := k.lookup(kid); ok {
+		return key, true
+	}
+	if !k.refresh() {
+		return nil, false
+	}
+	return k.lookup(kid)
+}
+
+func (k *Keyring) lookup(kid string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[kid]
+	if !ok || key.IsExpired() {
+		return nil, false
+	}
+	return key, true
+}
+
+func (k *Keyring) refresh() bool {
+	if k.Refresh == nil {
+		return false
+	}
+
+	k.refreshMu.Lock()
+	defer k.refreshMu.Unlock()
+	if time.Since(k.refreshedAt) < keyringRefreshInterval {
+		return false
+	}
+	k.refreshedAt = time.Now()
+	return k.Refresh() == nil
+}
+
+// Keys returns the unexpired keys, current key first.
+func (k *Keyring) Keys() []*SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := []*SigningKey{k.current}
+	for _, key := range k.keys {
+		if key != k.current && !key.IsExpired() {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
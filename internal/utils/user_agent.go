@@ -0,0 +1,56 @@
+package utils
+
+import "strings"
+
+// Markers are checked in order, so more specific products come before the
+// ones whose tokens they also send, such as Edge before Chrome before Safari.
+var (
+	browserMarkers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	platformMarkers = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DeviceName returns a short description of the device that sent the
+// User-Agent header, such as "Firefox on Windows".
+func DeviceName(userAgent string) string {
+	browser := matchMarker(userAgent, browserMarkers)
+	platform := matchMarker(userAgent, platformMarkers)
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	case userAgent == "":
+		return "Unknown device"
+	}
+	if len(userAgent) > 100 {
+		return userAgent[:100]
+	}
+	return userAgent
+}
+
+func matchMarker(userAgent string, markers []struct{ token, name string }) string {
+	for _, marker := range markers {
+		if strings.Contains(userAgent, marker.token) {
+			return marker.name
+		}
+	}
+	return ""
+}